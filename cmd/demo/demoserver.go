@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"strconv"
@@ -93,6 +94,7 @@ func main() {
 		writer.Write([]byte("hello"))
 	})
 	http.HandleFunc("/qn_download", HandleDownload)
+	http.HandleFunc("/qn_upload", HandleUpload)
 	http.HandleFunc("/redirect", func(writer http.ResponseWriter, request *http.Request) {
 		site := request.URL.Query().Get("q")
 		writer.Header().Set("Location", site)
@@ -115,7 +117,7 @@ func handler(w http.ResponseWriter, r *http.Request) {
 	if err == nil {
 		tinfo = *info
 	}
-	fmt.Printf("%+v\n", tinfo, err)
+	fmt.Printf("%+v %v\n", tinfo, err)
 	p := (*[infoSize]byte)(unsafe.Pointer(&tinfo))[:]
 	fmt.Println(p)
 	w.Write(p)
@@ -146,3 +148,12 @@ func HandleDownload(w http.ResponseWriter, r *http.Request) {
 		w.Write(p)
 	}
 }
+
+func HandleUpload(w http.ResponseWriter, r *http.Request) {
+	n, err := io.Copy(io.Discard, r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.Write([]byte(strconv.FormatInt(n, 10)))
+}
@@ -1,71 +1,421 @@
 package main
 
 import (
-	"crypto/md5"
-	"crypto/sha1"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"hash"
-	"hash/crc32"
+	"net"
 	"net/http"
+	neturl "net/url"
+	"os"
+	"os/signal"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/qiniu/httpping/dns"
 	h "github.com/qiniu/httpping/http"
 )
 
+type headers []string
+
+func (h *headers) String() string {
+	return strings.Join(*h, ", ")
+}
+
+func (h *headers) Set(v string) error {
+	*h = append(*h, v)
+	return nil
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
 func main() {
 	url := flag.String("u", "www.baidu.com", "ping url")
+	method := flag.String("X", http.MethodGet, "http method")
+	var header headers
+	flag.Var(&header, "H", "extra request header like \"Content-Type: text/plain\", can be repeated")
+	uploadSize := flag.Int64("upload-size", 0, "upload random body of the size, bytes")
+	uploadFile := flag.String("upload-file", "", "upload the file as body")
 	ping := flag.Bool("p", true, "with system ping command")
 	local := flag.String("l", "", "local address")
 	range_ := flag.String("r", "", "http range")
+	rangeCheck := flag.Int("range-check", 0, "check n random single and multi range requests byte for byte against the full object, the exit code is 1 when any fails")
+	rangeSeed := flag.Int64("range-seed", 0, "seed of -range-check to repeat the same ranges, default the current time")
 	server := flag.Bool("s", false, "server support tcpinfo return")
-	hashStr := flag.String("hash", "", "body hash")
+	hashStr := flag.String("hash", "", "body hash: md5, sha1, sha256, crc32, crc64, xxhash or qetag, checked against ETag, Content-MD5 and the vendor hash headers")
+	expectHash := flag.String("expect-hash", "", "expected hex body hash, a different body fails with hash_mismatch")
+	expectStatus := flag.String("expect-status", "", "expected status codes like 200,204, the exit code is 1 when any assertion fails")
+	var expectHeaders, expectHeaderRegex, expectBody, expectBodyRegex headers
+	flag.Var(&expectHeaders, "expect-header", "expected response header like \"Content-Type: text/plain\", can be repeated")
+	flag.Var(&expectHeaderRegex, "expect-header-regex", "response header matching a regexp like \"Server: ^nginx\", can be repeated")
+	flag.Var(&expectBody, "expect-body", "text the body contains, can be repeated")
+	flag.Var(&expectBodyRegex, "expect-body-regex", "regexp the body matches, can be repeated")
+	maxTtfb := flag.Float64("max-ttfb", 0, "max ttfb, seconds")
+	maxTotal := flag.Float64("max-total", 0, "max total time, seconds")
+	minSpeed := flag.Float64("min-speed", 0, "min speed, kb/s")
 	ua := flag.String("ua", "", "user agent")
-	redirect := flag.Bool("redirect", false, "enable redirect")
+	redirect := flag.Bool("redirect", false, "enable redirect, not with h3")
+	cdn := flag.Bool("cdn", false, "classify the cdn cache status (hit, miss, stale) and the edge from the response headers")
+	var resolves headers
+	flag.Var(&resolves, "resolve", "host:ip to connect to for host at every redirect, -ip only applies to the first request, can be repeated")
 	timeout := flag.Int64("timeout", 10, "total timeout, seconds")
+	dnsTimeout := flag.Float64("dns-timeout", 0, "dns timeout, seconds, 0 means no limit")
+	connectTimeout := flag.Float64("connect-timeout", 1, "tcp connect timeout, seconds")
+	tlsTimeout := flag.Float64("tls-timeout", 0, "tls handshake timeout, seconds, 0 means no limit")
+	ttfbTimeout := flag.Float64("ttfb-timeout", 0, "timeout from sending the request to the first response byte, seconds, 0 means no limit")
+	bodyTimeout := flag.Float64("body-timeout", 0, "body download timeout, seconds, 0 means no limit")
 	ip := flag.String("ip", "", "server ip")
 	verifyHost := flag.Bool("verify", true, "verify host cert")
+	certFile := flag.String("cert", "", "client certificate pem file for mutual tls")
+	keyFile := flag.String("key", "", "client private key pem file, default the cert file")
+	caFile := flag.String("cacert", "", "extra ca bundle pem file trusted besides the system roots")
+	var pins headers
+	flag.Var(&pins, "pin", "base64 sha256 of a SPKI in the server chain, can be repeated")
+	count := flag.Int("c", 1, "ping count, 0 means until interrupted")
+	interval := flag.Float64("i", 1, "interval between pings, seconds")
+	all := flag.Bool("all", false, "probe every resolved ip of the domain concurrently")
+	parallel := flag.Int("parallel", 0, "speed test over n parallel connections, with the aggregate and per connection speed and the fairness")
+	parallelRanges := flag.Bool("parallel-ranges", false, "each -parallel connection downloads its own range of the object instead of the whole url")
+	consistency := flag.Bool("consistency", false, "compare the hash, Content-Length, ETag and Last-Modified served by every ip, the exit code is 1 when they differ")
+	ips := flag.String("ips", "", "ips like 1.2.3.4,5.6.7.8 for -consistency, default all the resolved ips")
+	origin := flag.String("origin", "", "origin host or ip also compared by -consistency")
+	dual := flag.Bool("dual", false, "probe both ipv4 and ipv6 of the domain")
+	ipv4 := flag.Bool("4", false, "use ipv4 only")
+	ipv6 := flag.Bool("6", false, "use ipv6 only")
+	resolver := flag.String("dns", "system", "resolver: system, 8.8.8.8, tcp://8.8.8.8:53, tls://dns.alidns.com, https://dns.alidns.com/dns-query")
+	keepAlive := flag.Int("keepalive", 0, "send the count of requests over one persistent connection")
+	proxy := flag.String("proxy", "", "tunnel through the proxy, http://[user:pass@]host:port or socks5://[user:pass@]host:port")
+	seriesInterval := flag.Float64("series-interval", 0.1, "interval of the throughput time series, seconds")
+	tcpSample := flag.Float64("tcp-sample", 0, "sample TCP_INFO at the interval while the body downloads, seconds, 0 disables")
+	resume := flag.Bool("resume", false, "measure tls session resumption with a second handshake")
+	proto := flag.String("proto", h.ProtoHTTP1, "http protocol, http1.1, h2 or h3")
 	flag.Parse()
 
-	req, err := http.NewRequest(http.MethodGet, *url, nil)
+	req, err := http.NewRequest(strings.ToUpper(*method), *url, nil)
 	if err != nil {
 		fmt.Println(err)
 		flag.PrintDefaults()
 		return
 	}
+	for _, kv := range header {
+		k, v, _ := strings.Cut(kv, ":")
+		req.Header.Add(strings.TrimSpace(k), strings.TrimSpace(v))
+	}
+	if *uploadFile != "" {
+		err = h.SetFileBody(req, *uploadFile)
+		if err != nil {
+			fmt.Println(err)
+			flag.PrintDefaults()
+			return
+		}
+	} else if *uploadSize > 0 {
+		h.SetRandomBody(req, *uploadSize)
+	}
 	if *range_ != "" {
 		req.Header.Set("Range", "bytes="+*range_)
 	}
 	if *ua != "" {
 		req.Header.Set("User-Agent", *ua)
 	}
+	var newHasher func() hash.Hash
 	var hasher hash.Hash
-	switch strings.ToLower(*hashStr) {
-	case "md5":
-		hasher = md5.New()
-	case "sha1":
-		hasher = sha1.New()
-	case "crc":
-		hasher = crc32.NewIEEE()
+	if *hashStr == "crc" {
+		// the old name of crc32
+		*hashStr = h.HashCRC32
+	}
+	if *hashStr != "" {
+		hr, ok := h.GetHasher(*hashStr)
+		if !ok {
+			fmt.Printf("unknown hash %q, expected one of %s\n", *hashStr, strings.Join(h.Hashers(), ", "))
+			flag.PrintDefaults()
+			return
+		}
+		newHasher = hr.New
+		hasher = newHasher()
+	}
+	if *expectHash != "" && hasher == nil {
+		fmt.Println("-expect-hash needs -hash, no hasher to check it")
+		flag.PrintDefaults()
+		return
+	}
+
+	r, err := dns.New(*resolver)
+	if err != nil {
+		fmt.Println(err)
+		flag.PrintDefaults()
+		return
+	}
+
+	var clientCerts []tls.Certificate
+	if *certFile != "" {
+		if *keyFile == "" {
+			*keyFile = *certFile
+		}
+		cert, err := tls.LoadX509KeyPair(*certFile, *keyFile)
+		if err != nil {
+			fmt.Println(err)
+			flag.PrintDefaults()
+			return
+		}
+		clientCerts = []tls.Certificate{cert}
+	}
+	var rootCAs *x509.CertPool
+	if *caFile != "" {
+		rootCAs, err = h.LoadRootCAs(*caFile)
+		if err != nil {
+			fmt.Println(err)
+			flag.PrintDefaults()
+			return
+		}
+	}
+
+	var proxyURL *neturl.URL
+	if *proxy != "" {
+		proxyURL, err = h.ParseProxy(*proxy)
+		if err != nil {
+			fmt.Println(err)
+			flag.PrintDefaults()
+			return
+		}
+	}
+
+	var hostIps map[string]string
+	for _, r := range resolves {
+		host, ip, ok := strings.Cut(r, ":")
+		if !ok || net.ParseIP(ip) == nil {
+			fmt.Printf("invalid resolve %q, expected host:ip\n", r)
+			flag.PrintDefaults()
+			return
+		}
+		if hostIps == nil {
+			hostIps = map[string]string{}
+		}
+		hostIps[host] = ip
+	}
+
+	var assertions *h.Assertions
+	if *expectStatus != "" || len(expectHeaders) != 0 || len(expectHeaderRegex) != 0 || len(expectBody) != 0 ||
+		len(expectBodyRegex) != 0 || *maxTtfb > 0 || *maxTotal > 0 || *minSpeed > 0 || *expectHash != "" {
+		assertions, err = parseAssertions(*expectStatus, expectHeaders, expectHeaderRegex, expectBodyRegex)
+		if err != nil {
+			fmt.Println(err)
+			flag.PrintDefaults()
+			return
+		}
+		assertions.BodyContains = expectBody
+		assertions.MaxTtfb = seconds(*maxTtfb)
+		assertions.MaxTotal = seconds(*maxTotal)
+		assertions.MinSpeed = float32(*minSpeed)
+	}
+
+	network := ""
+	if *ipv4 {
+		network = "tcp4"
+	} else if *ipv6 {
+		network = "tcp6"
 	}
 
 	p := h.Pinger{
-		Req:           req,
-		SysPing:       *ping,
-		SrcAddr:       *local,
-		ServerSupport: *server,
-		BodyHasher:    hasher,
-		Redirect:      *redirect,
-		Timeout:       time.Duration(*timeout) * time.Second,
-		ServerIp:      *ip,
-		VerifyHost:    *verifyHost,
-	}
-	info, err := p.Ping()
+		Req:                req,
+		SysPing:            *ping,
+		SrcAddr:            *local,
+		ServerSupport:      *server,
+		BodyHasher:         hasher,
+		NewHasher:          newHasher,
+		HashAlgo:           *hashStr,
+		Redirect:           *redirect,
+		HostIps:            hostIps,
+		CDN:                *cdn,
+		Timeout:            time.Duration(*timeout) * time.Second,
+		ServerIp:           *ip,
+		VerifyHost:         *verifyHost,
+		Count:              *count,
+		Interval:           seconds(*interval),
+		Proto:              *proto,
+		Network:            network,
+		Resolver:           r,
+		ClientCerts:        clientCerts,
+		RootCAs:            rootCAs,
+		Pins:               pins,
+		Proxy:              proxyURL,
+		ExpectedHash:       *expectHash,
+		Assertions:         assertions,
+		ThroughputInterval: seconds(*seriesInterval),
+		TCPSampleInterval:  seconds(*tcpSample),
+		Timeouts: h.Timeouts{
+			Dns:     seconds(*dnsTimeout),
+			Connect: seconds(*connectTimeout),
+			TLS:     seconds(*tlsTimeout),
+			Ttfb:    seconds(*ttfbTimeout),
+			Body:    seconds(*bodyTimeout),
+		},
+	}
+	if *all {
+		result, err := p.PingAllIps(context.Background())
+		if err != nil {
+			fmt.Println(err)
+			flag.PrintDefaults()
+			return
+		}
+		fmt.Println(result.String())
+		return
+	}
+	if *parallel > 0 {
+		result, err := p.PingParallel(context.Background(), *parallel, *parallelRanges)
+		if result == nil {
+			fmt.Println(err)
+			flag.PrintDefaults()
+			return
+		}
+		fmt.Println(result.String())
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+	if *rangeCheck > 0 {
+		result, err := p.PingRanges(context.Background(), *rangeCheck, *rangeSeed)
+		if err != nil {
+			fmt.Println(err)
+			flag.PrintDefaults()
+			return
+		}
+		fmt.Println(result.String())
+		if !result.Ok {
+			os.Exit(1)
+		}
+		return
+	}
+	if *consistency {
+		var nodes []string
+		if *ips != "" {
+			nodes = strings.Split(*ips, ",")
+		}
+		result, err := p.PingConsistency(context.Background(), nodes, *origin)
+		if err != nil {
+			fmt.Println(err)
+			flag.PrintDefaults()
+			return
+		}
+		fmt.Println(result.String())
+		if !result.Consistent {
+			os.Exit(1)
+		}
+		return
+	}
+	if *resume {
+		result, err := p.PingResume(context.Background())
+		if err != nil {
+			fmt.Println(err)
+			flag.PrintDefaults()
+			return
+		}
+		fmt.Println(result.String())
+		return
+	}
+	if *keepAlive > 0 {
+		result, err := p.PingKeepAlive(context.Background(), *keepAlive)
+		if err != nil {
+			fmt.Println(err)
+			flag.PrintDefaults()
+			return
+		}
+		fmt.Println(result.String())
+		return
+	}
+	if *dual {
+		result, err := p.PingDualStack(context.Background())
+		if err != nil {
+			fmt.Println(err)
+			flag.PrintDefaults()
+			return
+		}
+		fmt.Println(result.String())
+		return
+	}
+	if *count == 1 {
+		info, err := p.Ping()
+		if info == nil {
+			fmt.Println(err)
+			flag.PrintDefaults()
+			return
+		}
+		fmt.Println(info.String())
+		if info.Verdict == h.VerdictFail {
+			os.Exit(1)
+		}
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	failed := false
+	result, err := p.PingCount(ctx, func(seq int, info *h.Info) {
+		fmt.Printf("seq=%d %s\n", seq, info.String())
+		failed = failed || info.Verdict == h.VerdictFail
+	})
+	stop()
 	if err != nil {
 		fmt.Println(err)
 		flag.PrintDefaults()
 		return
 	}
-	fmt.Println(info.String())
+	summary, _ := json.MarshalIndent(result.Summary, "", "	")
+	fmt.Println(string(summary))
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func parseAssertions(status string, headerValues, headerRegex, bodyRegex []string) (*h.Assertions, error) {
+	a := &h.Assertions{}
+	if status != "" {
+		for _, code := range strings.Split(status, ",") {
+			c, err := strconv.Atoi(strings.TrimSpace(code))
+			if err != nil {
+				return nil, fmt.Errorf("invalid status %q", code)
+			}
+			a.Status = append(a.Status, c)
+		}
+	}
+	for _, kv := range headerValues {
+		k, v, err := h.ParseHeaderAssertion(kv)
+		if err != nil {
+			return nil, err
+		}
+		if a.Headers == nil {
+			a.Headers = map[string]string{}
+		}
+		a.Headers[k] = v
+	}
+	for _, kv := range headerRegex {
+		k, v, err := h.ParseHeaderAssertion(kv)
+		if err != nil {
+			return nil, err
+		}
+		re, err := regexp.Compile(v)
+		if err != nil {
+			return nil, err
+		}
+		if a.HeaderRegex == nil {
+			a.HeaderRegex = map[string]*regexp.Regexp{}
+		}
+		a.HeaderRegex[k] = re
+	}
+	for _, expr := range bodyRegex {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, err
+		}
+		a.BodyRegex = append(a.BodyRegex, re)
+	}
+	return a, nil
 }
@@ -31,17 +31,22 @@ func (ce ConversionError) Error() string {
 	return fmt.Sprintf("%s: %v", ce.Context, ce.Err)
 }
 
+const ipRx = `\d+\.\d+\.\d+\.\d+|[0-9a-fA-F]*:[0-9a-fA-F:.]*`
+
 var (
-	headerRx         = regexp.MustCompile(`^PING (?P<host>.*) \((?P<resolvedIPAddress>\d+\.\d+\.\d+\.\d+)\)( from .* :)? (?P<payloadSize>\d+)\((?P<payloadActualSize>\d+)\) bytes of data`)
-	headerRxAlt      = regexp.MustCompile(`^PING (?P<host>.*) \((?P<resolvedIPAddress>\d+\.\d+\.\d+\.\d+)\)( from .*)?: (?P<payloadSize>\d+) data bytes`)
-	lineRx           = regexp.MustCompile(`^(?P<replySize>\d+) bytes from (?P<fromAddress>\d+\.\d+\.\d+\.\d+): icmp_seq=(?P<seqNo>\d+) ttl=(?P<ttl>\d+) time=(?P<time>.*)$`)
-	statsSeparatorRx = regexp.MustCompile(`^--- (?P<IPAddress>.*) ping statistics ---$`)
+	headerRx         = regexp.MustCompile(`^PING (?P<host>.*) \((?P<resolvedIPAddress>` + ipRx + `)\)( from .* :)? (?P<payloadSize>\d+)\((?P<payloadActualSize>\d+)\) bytes of data`)
+	headerRxAlt      = regexp.MustCompile(`^PING (?P<host>.*) \((?P<resolvedIPAddress>` + ipRx + `)\)( from .*)?: (?P<payloadSize>\d+) data bytes`)
+	headerRx6        = regexp.MustCompile(`^PING (?P<host>[^ (]*) ?\((?P<resolvedIPAddress>` + ipRx + `)\)( from .*)? (?P<payloadSize>\d+) data bytes`)
+	headerRx6Mac     = regexp.MustCompile(`^PING6\((?P<payloadActualSize>\d+)=\d+\+\d+\+(?P<payloadSize>\d+) bytes\) (?P<host>\S+) --> (?P<resolvedIPAddress>\S+)$`)
+	lineRx           = regexp.MustCompile(`^(?P<replySize>\d+) bytes from (?P<fromAddress>` + ipRx + `): icmp_seq=(?P<seqNo>\d+) ttl=(?P<ttl>\d+) time=(?P<time>.*)$`)
+	lineRx6Mac       = regexp.MustCompile(`^(?P<replySize>\d+) bytes from (?P<fromAddress>[^,]+), icmp_seq=(?P<seqNo>\d+) hlim=(?P<ttl>\d+) time=(?P<time>.*)$`)
+	statsSeparatorRx = regexp.MustCompile(`^--- (?P<IPAddress>.*) ping6? statistics ---$`)
 	statsLine1       = regexp.MustCompile(`^(?P<packetsTransmitted>\d+) packets transmitted, (?P<packetsReceived>\d+) (packets )?received,( \+(?P<errors>\d+) errors,)?( \+(?P<duplicates>\d+) duplicates,)?( (?P<packetLoss>.*)% packet loss)?(, time (?P<time>.*))?( \-\- (?P<warning>.*))?$`)
-	statsLine2       = regexp.MustCompile(`^(rtt|round-trip) min/avg/max/(mdev|stddev) = (?P<min>[^/]+)/(?P<avg>[^/]+)/(?P<max>[^/]+)/(?P<mdev>[^ ]+) (?P<unit>.*)$`)
+	statsLine2       = regexp.MustCompile(`^(rtt|round-trip) min/avg/max/(mdev|stddev|std-dev) = (?P<min>[^/]+)/(?P<avg>[^/]+)/(?P<max>[^/]+)/(?P<mdev>[^ ]+) (?P<unit>.*)$`)
 	pipeNo           = regexp.MustCompile(`(?P<unit>[^,]+), pipe (?P<pipeNo>\d+)$`)
 	pipeNoLine       = regexp.MustCompile(`^pipe (?P<pipeNo>\d+)$`)
-	hostErrorLineRx1 = regexp.MustCompile(`^From (?P<fromAddress>\d+\.\d+\.\d+\.\d+) icmp_seq=(?P<seqNo>\d+) (?P<error>.*)$`)
-	hostErrorLineRx2 = regexp.MustCompile(`^(?P<replySize>\d+) bytes from (?P<fromAddress>\d+\.\d+\.\d+\.\d+): (?P<error>.*)$`)
+	hostErrorLineRx1 = regexp.MustCompile(`^From (?P<fromAddress>` + ipRx + `) icmp_seq=(?P<seqNo>\d+) (?P<error>.*)$`)
+	hostErrorLineRx2 = regexp.MustCompile(`^(?P<replySize>\d+) bytes from (?P<fromAddress>` + ipRx + `): (?P<error>.*)$`)
 )
 
 // PingOutput contains the whole ping operation output.
@@ -109,12 +114,18 @@ func Parse(s string) (*PingOutput, error) {
 	var result map[string]string
 	if runtime.GOOS == "darwin" {
 		result = matchAsMap(headerRxAlt, lines[0])
+		if len(result) == 0 {
+			result = matchAsMap(headerRx6Mac, lines[0])
+		}
 	} else {
 		result = matchAsMap(headerRx, lines[0])
 		if len(result) == 0 {
 			result = matchAsMap(headerRxAlt, lines[0])
 			if len(result) == 0 {
-				return nil, ErrHeaderMismatch
+				result = matchAsMap(headerRx6, lines[0])
+				if len(result) == 0 {
+					return nil, ErrHeaderMismatch
+				}
 			}
 		}
 	}
@@ -151,6 +162,9 @@ func Parse(s string) (*PingOutput, error) {
 		}
 
 		result = matchAsMap(lineRx, line)
+		if len(result) == 0 {
+			result = matchAsMap(lineRx6Mac, line)
+		}
 		if len(result) == 0 {
 			// try to match a host error line
 			result = matchAsMap(hostErrorLineRx1, line)
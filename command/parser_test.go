@@ -23,3 +23,31 @@ func TestPingResult(t *testing.T) {
 	result = matchAsMap(headerRx, s)
 	assert.NotEmpty(t, result)
 }
+
+func TestParseIPv6(t *testing.T) {
+	s := `PING 2408:8000:1010:1::8(2408:8000:1010:1::8) 56 data bytes
+64 bytes from 2408:8000:1010:1::8: icmp_seq=1 ttl=54 time=24.1 ms
+64 bytes from 2408:8000:1010:1::8: icmp_seq=2 ttl=54 time=23.9 ms
+
+--- 2408:8000:1010:1::8 ping statistics ---
+2 packets transmitted, 2 received, 0% packet loss, time 1001ms
+rtt min/avg/max/mdev = 23.912/24.006/24.100/0.094 ms
+`
+	po, err := Parse(s)
+	assert.Nil(t, err)
+	assert.Equal(t, "2408:8000:1010:1::8", po.ResolvedIPAddress)
+	assert.Len(t, po.Replies, 2)
+	assert.Equal(t, "2408:8000:1010:1::8", po.Replies[0].FromAddress)
+	assert.Equal(t, uint(54), po.Replies[0].TTL)
+	assert.Equal(t, uint(2), po.Stats.PacketsReceived)
+
+	s = "PING www.a.shifen.com (240e:e9:6002:15c:0:ff:b015:146f) 56 data bytes"
+	result := matchAsMap(headerRx6, s)
+	assert.Equal(t, "240e:e9:6002:15c:0:ff:b015:146f", result["resolvedIPAddress"])
+	s = "PING6(56=40+8+8 bytes) 2409:8a1e::1 --> 240e:e9:6002:15c:0:ff:b015:146f"
+	result = matchAsMap(headerRx6Mac, s)
+	assert.Equal(t, "240e:e9:6002:15c:0:ff:b015:146f", result["resolvedIPAddress"])
+	s = "16 bytes from 240e:e9:6002:15c:0:ff:b015:146f, icmp_seq=0 hlim=52 time=30.112 ms"
+	result = matchAsMap(lineRx6Mac, s)
+	assert.Equal(t, "52", result["ttl"])
+}
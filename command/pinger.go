@@ -4,6 +4,7 @@ package command
 import (
 	"bytes"
 	"fmt"
+	"net"
 	"os/exec"
 	"runtime"
 	"strconv"
@@ -11,16 +12,21 @@ import (
 	"syscall"
 )
 
-// Ping will ping the specified IPv4 address with the provided timeout, interval and size settings .
-func Ping(ipV4Address string, interval, timeout int, count int, sourceAddr string) (*PingOutput, error) {
+// Ping will ping the specified IPv4 or IPv6 address with the provided timeout, interval and size settings .
+func Ping(address string, interval, timeout int, count int, sourceAddr string) (*PingOutput, error) {
 	var (
 		output, errorOutput bytes.Buffer
 		exitCode            int
 	)
 	var pingArgs = []string{"-n", "-i", strconv.Itoa(interval), "-c", strconv.Itoa(count)}
 	if sourceAddr != "" {
-		array := strings.Split(sourceAddr, ":")
-		sourceAddr = array[0]
+		if host, _, err := net.SplitHostPort(sourceAddr); err == nil {
+			sourceAddr = host
+		}
+	}
+	name := "ping"
+	if ip := net.ParseIP(address); ip != nil && ip.To4() == nil && runtime.GOOS == "darwin" {
+		name = "ping6"
 	}
 	if runtime.GOOS == "darwin" {
 		if sourceAddr != "" {
@@ -31,8 +37,8 @@ func Ping(ipV4Address string, interval, timeout int, count int, sourceAddr strin
 			pingArgs = append(pingArgs, "-I", sourceAddr)
 		}
 	}
-	pingArgs = append(pingArgs, ipV4Address)
-	cmd := exec.Command("ping", pingArgs...)
+	pingArgs = append(pingArgs, address)
+	cmd := exec.Command(name, pingArgs...)
 	cmd.Stdout = &output
 	cmd.Stderr = &errorOutput
 	err := cmd.Run()
@@ -52,7 +58,7 @@ func Ping(ipV4Address string, interval, timeout int, count int, sourceAddr strin
 	}
 
 	// in case of error, use also the execution context errors (if any)
-	return nil, fmt.Errorf("command: ping %s\nexit code: %d\nparse error: %v\nstdout:\n%s\nstderr:\n%s", strings.Join(pingArgs, " "), exitCode, err, output.String(), errorOutput.String())
+	return nil, fmt.Errorf("command: %s %s\nexit code: %d\nparse error: %v\nstdout:\n%s\nstderr:\n%s", name, strings.Join(pingArgs, " "), exitCode, err, output.String(), errorOutput.String())
 }
 
 func parseExitCode(err error) (int, error) {
@@ -0,0 +1,168 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// RcodeError is returned when the server answers with an error code, such as NXDOMAIN or SERVFAIL.
+type RcodeError struct {
+	Host  string
+	RCode dnsmessage.RCode
+}
+
+func (e *RcodeError) Error() string {
+	var name string
+	switch e.RCode {
+	case dnsmessage.RCodeNameError:
+		name = "NXDOMAIN"
+	case dnsmessage.RCodeServerFailure:
+		name = "SERVFAIL"
+	case dnsmessage.RCodeRefused:
+		name = "REFUSED"
+	default:
+		name = e.RCode.String()
+	}
+	return fmt.Sprintf("lookup %s: %s", e.Host, name)
+}
+
+var errIdMismatch = errors.New("dns response id mismatch")
+
+type exchangeFunc func(ctx context.Context, query []byte) ([]byte, error)
+
+func newQuery(host string, typ dnsmessage.Type) (uint16, []byte, error) {
+	name, err := dnsmessage.NewName(host)
+	if err != nil {
+		return 0, nil, err
+	}
+	id := uint16(rand.Uint32())
+	msg := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: name, Type: typ, Class: dnsmessage.ClassINET}},
+	}
+	b, err := msg.Pack()
+	return id, b, err
+}
+
+func truncated(msg []byte) bool {
+	return len(msg) > 2 && msg[2]&0x02 != 0
+}
+
+func parseAnswer(id uint16, msg []byte, host string, r *Result) error {
+	var p dnsmessage.Parser
+	h, err := p.Start(msg)
+	if err != nil {
+		return err
+	}
+	if h.ID != id {
+		return errIdMismatch
+	}
+	if h.RCode != dnsmessage.RCodeSuccess {
+		return &RcodeError{Host: host, RCode: h.RCode}
+	}
+	err = p.SkipAllQuestions()
+	if err != nil {
+		return err
+	}
+	answers, err := p.AllAnswers()
+	if err != nil {
+		return err
+	}
+	for _, a := range answers {
+		if r.TTL == 0 || a.Header.TTL < r.TTL {
+			r.TTL = a.Header.TTL
+		}
+		switch body := a.Body.(type) {
+		case *dnsmessage.CNAMEResource:
+			cname := strings.TrimSuffix(body.CNAME.String(), ".")
+			if !contains(r.CNAMEs, cname) {
+				r.CNAMEs = append(r.CNAMEs, cname)
+			}
+		case *dnsmessage.AResource:
+			r.Addrs = append(r.Addrs, net.IP(body.A[:]).String())
+		case *dnsmessage.AAAAResource:
+			r.Addrs = append(r.Addrs, net.IP(body.AAAA[:]).String())
+		}
+	}
+	return nil
+}
+
+func contains(l []string, s string) bool {
+	for _, v := range l {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// lookup sends the A and AAAA queries concurrently and merges the answers.
+func lookup(ctx context.Context, network, host, resolver string, exchange exchangeFunc) (*Result, error) {
+	fqdn := host
+	if !strings.HasSuffix(fqdn, ".") {
+		fqdn += "."
+	}
+	var types []dnsmessage.Type
+	switch network {
+	case "ip4":
+		types = []dnsmessage.Type{dnsmessage.TypeA}
+	case "ip6":
+		types = []dnsmessage.Type{dnsmessage.TypeAAAA}
+	default:
+		types = []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA}
+	}
+
+	results := make([]Result, len(types))
+	errs := make([]error, len(types))
+	var wg sync.WaitGroup
+	for i, typ := range types {
+		wg.Add(1)
+		go func(i int, typ dnsmessage.Type) {
+			defer wg.Done()
+			id, query, err := newQuery(fqdn, typ)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			answer, err := exchange(ctx, query)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			errs[i] = parseAnswer(id, answer, host, &results[i])
+		}(i, typ)
+	}
+	wg.Wait()
+
+	r := &Result{Resolver: resolver}
+	for i := range results {
+		if errs[i] != nil {
+			continue
+		}
+		if results[i].TTL != 0 && (r.TTL == 0 || results[i].TTL < r.TTL) {
+			r.TTL = results[i].TTL
+		}
+		for _, cname := range results[i].CNAMEs {
+			if !contains(r.CNAMEs, cname) {
+				r.CNAMEs = append(r.CNAMEs, cname)
+			}
+		}
+		r.Addrs = append(r.Addrs, results[i].Addrs...)
+	}
+	if len(r.Addrs) != 0 {
+		return r, nil
+	}
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, Server: resolver, IsNotFound: true}
+}
@@ -0,0 +1,77 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+type Result struct {
+	Resolver string
+	TTL      uint32 // min ttl of the answers, 0 for the system resolver
+	CNAMEs   []string
+	Addrs    []string
+}
+
+type Resolver interface {
+	// Resolve looks up host, network is ip, ip4 or ip6.
+	Resolve(ctx context.Context, network, host string) (*Result, error)
+	String() string
+}
+
+// System uses the resolver of the operating system, it can not tell the ttl and the cname chain.
+type System struct{}
+
+func (System) Resolve(ctx context.Context, network, host string) (*Result, error) {
+	ips, err := net.DefaultResolver.LookupIP(ctx, network, host)
+	if err != nil {
+		return nil, err
+	}
+	r := &Result{Resolver: "system"}
+	for _, ip := range ips {
+		r.Addrs = append(r.Addrs, ip.String())
+	}
+	return r, nil
+}
+
+func (System) String() string {
+	return "system"
+}
+
+func withPort(addr, port string) string {
+	if _, _, err := net.SplitHostPort(addr); err == nil {
+		return addr
+	}
+	return net.JoinHostPort(strings.Trim(addr, "[]"), port)
+}
+
+// New creates a resolver from spec:
+//
+//	system                          the system resolver
+//	8.8.8.8, udp://8.8.8.8:53       plain dns over udp, falls back to tcp when truncated
+//	tcp://8.8.8.8:53                plain dns over tcp
+//	tls://dns.alidns.com:853        dns over tls
+//	https://dns.alidns.com/dns-query  dns over https
+func New(spec string) (Resolver, error) {
+	if spec == "" || spec == "system" {
+		return System{}, nil
+	}
+	if !strings.Contains(spec, "://") {
+		return &Plain{Network: "udp", Server: withPort(spec, "53")}, nil
+	}
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "udp", "tcp":
+		return &Plain{Network: u.Scheme, Server: withPort(u.Host, "53")}, nil
+	case "tls":
+		return &TLS{Server: withPort(u.Host, "853")}, nil
+	case "https":
+		return &HTTPS{URL: spec}, nil
+	}
+	return nil, fmt.Errorf("unsupported resolver %s", spec)
+}
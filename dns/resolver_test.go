@@ -0,0 +1,185 @@
+package dns
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// stubAnswer answers www.example.com through a cname, everything else is NXDOMAIN.
+func stubAnswer(t *testing.T, query []byte) []byte {
+	var p dnsmessage.Parser
+	h, err := p.Start(query)
+	assert.Nil(t, err)
+	q, err := p.Question()
+	assert.Nil(t, err)
+
+	h.Response = true
+	h.RecursionAvailable = true
+	if q.Name.String() != "www.example.com." {
+		h.RCode = dnsmessage.RCodeNameError
+	}
+	b := dnsmessage.NewBuilder(nil, h)
+	b.EnableCompression()
+	assert.Nil(t, b.StartQuestions())
+	assert.Nil(t, b.Question(q))
+	assert.Nil(t, b.StartAnswers())
+	if h.RCode == dnsmessage.RCodeSuccess {
+		cname := dnsmessage.MustNewName("cdn.example.net.")
+		assert.Nil(t, b.CNAMEResource(dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET, TTL: 600},
+			dnsmessage.CNAMEResource{CNAME: cname}))
+		switch q.Type {
+		case dnsmessage.TypeA:
+			assert.Nil(t, b.AResource(dnsmessage.ResourceHeader{Name: cname, Class: dnsmessage.ClassINET, TTL: 60},
+				dnsmessage.AResource{A: [4]byte{127, 0, 0, 1}}))
+		case dnsmessage.TypeAAAA:
+			assert.Nil(t, b.AAAAResource(dnsmessage.ResourceHeader{Name: cname, Class: dnsmessage.ClassINET, TTL: 30},
+				dnsmessage.AAAAResource{AAAA: [16]byte{15: 1}}))
+		}
+	}
+	msg, err := b.Finish()
+	assert.Nil(t, err)
+	return msg
+}
+
+func stubServer(t *testing.T) (udpAddr, tcpAddr string, stop func()) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	go func() {
+		b := make([]byte, 512)
+		for {
+			n, addr, err := pc.ReadFrom(b)
+			if err != nil {
+				return
+			}
+			pc.WriteTo(stubAnswer(t, b[:n]), addr)
+		}
+	}()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	go serveStream(t, l)
+	return pc.LocalAddr().String(), l.Addr().String(), func() {
+		pc.Close()
+		l.Close()
+	}
+}
+
+// serveStream answers the length prefixed queries of tcp and tls.
+func serveStream(t *testing.T, l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		var size [2]byte
+		query, err := readStream(conn, size[:])
+		if err != nil {
+			// like a failed tls handshake
+			conn.Close()
+			continue
+		}
+		answer := stubAnswer(t, query)
+		binary.BigEndian.PutUint16(size[:], uint16(len(answer)))
+		conn.Write(append(size[:], answer...))
+		conn.Close()
+	}
+}
+
+func readStream(conn net.Conn, size []byte) ([]byte, error) {
+	if _, err := io.ReadFull(conn, size); err != nil {
+		return nil, err
+	}
+	query := make([]byte, binary.BigEndian.Uint16(size))
+	_, err := io.ReadFull(conn, query)
+	return query, err
+}
+
+func TestResolver(t *testing.T) {
+	udpAddr, tcpAddr, stop := stubServer(t)
+	defer stop()
+
+	for _, spec := range []string{udpAddr, "tcp://" + tcpAddr} {
+		r, err := New(spec)
+		assert.Nil(t, err)
+		res, err := r.Resolve(context.Background(), "ip", "www.example.com")
+		assert.Nil(t, err)
+		assert.Equal(t, r.String(), res.Resolver)
+		assert.Equal(t, []string{"cdn.example.net"}, res.CNAMEs)
+		assert.ElementsMatch(t, []string{"127.0.0.1", "::1"}, res.Addrs)
+		assert.Equal(t, uint32(30), res.TTL)
+
+		res, err = r.Resolve(context.Background(), "ip4", "www.example.com")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"127.0.0.1"}, res.Addrs)
+		assert.Equal(t, uint32(60), res.TTL)
+
+		_, err = r.Resolve(context.Background(), "ip", "nx.example.com")
+		rcodeErr, ok := err.(*RcodeError)
+		assert.True(t, ok)
+		assert.Equal(t, dnsmessage.RCodeNameError, rcodeErr.RCode)
+	}
+}
+
+func TestEncryptedResolver(t *testing.T) {
+	doh := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/dns-query" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/dns-message" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		query, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(stubAnswer(t, query))
+	}))
+	defer doh.Close()
+
+	// the dot server uses the certificate of the doh one, for 127.0.0.1
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: doh.TLS.Certificates})
+	assert.Nil(t, err)
+	defer l.Close()
+	go serveStream(t, l)
+	trusted := doh.Client().Transport.(*http.Transport).TLSClientConfig
+
+	for _, r := range []Resolver{
+		&TLS{Server: l.Addr().String(), Config: trusted},
+		&HTTPS{URL: doh.URL + "/dns-query", Client: doh.Client()},
+	} {
+		res, err := r.Resolve(context.Background(), "ip", "www.example.com")
+		assert.Nil(t, err)
+		assert.Equal(t, r.String(), res.Resolver)
+		assert.Equal(t, []string{"cdn.example.net"}, res.CNAMEs)
+		assert.ElementsMatch(t, []string{"127.0.0.1", "::1"}, res.Addrs)
+		assert.Equal(t, uint32(30), res.TTL)
+
+		_, err = r.Resolve(context.Background(), "ip4", "nx.example.com")
+		rcodeErr, ok := err.(*RcodeError)
+		assert.True(t, ok)
+		assert.Equal(t, dnsmessage.RCodeNameError, rcodeErr.RCode)
+	}
+
+	// the certificate is not trusted without the config of the test server
+	r, err := New("tls://" + l.Addr().String())
+	assert.Nil(t, err)
+	_, err = r.Resolve(context.Background(), "ip4", "www.example.com")
+	assert.NotNil(t, err)
+	r, err = New(doh.URL + "/dns-query")
+	assert.Nil(t, err)
+	_, err = r.Resolve(context.Background(), "ip4", "www.example.com")
+	assert.NotNil(t, err)
+
+	r = &HTTPS{URL: doh.URL + "/other", Client: doh.Client()}
+	_, err = r.Resolve(context.Background(), "ip4", "www.example.com")
+	assert.Contains(t, err.Error(), "returns 404")
+}
@@ -0,0 +1,159 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+const defaultTimeout = 5 * time.Second
+
+func withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, defaultTimeout)
+}
+
+// exchangeStream sends the query with the two bytes length prefix used by tcp and tls.
+func exchangeStream(ctx context.Context, conn net.Conn, query []byte) ([]byte, error) {
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	b := make([]byte, 2+len(query))
+	binary.BigEndian.PutUint16(b, uint16(len(query)))
+	copy(b[2:], query)
+	_, err := conn.Write(b)
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.ReadFull(conn, b[:2])
+	if err != nil {
+		return nil, err
+	}
+	answer := make([]byte, binary.BigEndian.Uint16(b))
+	_, err = io.ReadFull(conn, answer)
+	return answer, err
+}
+
+// Plain is the classic dns over udp or tcp.
+type Plain struct {
+	Network string
+	Server  string
+}
+
+func (p *Plain) String() string {
+	return p.Network + "://" + p.Server
+}
+
+func (p *Plain) Resolve(ctx context.Context, network, host string) (*Result, error) {
+	return lookup(ctx, network, host, p.String(), p.exchange)
+}
+
+func (p *Plain) exchange(ctx context.Context, query []byte) ([]byte, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	var d net.Dialer
+	if p.Network == "tcp" {
+		conn, err := d.DialContext(ctx, "tcp", p.Server)
+		if err != nil {
+			return nil, err
+		}
+		return exchangeStream(ctx, conn, query)
+	}
+
+	conn, err := d.DialContext(ctx, "udp", p.Server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	_, err = conn.Write(query)
+	if err != nil {
+		return nil, err
+	}
+	answer := make([]byte, 65535)
+	n, err := conn.Read(answer)
+	if err != nil {
+		return nil, err
+	}
+	if truncated(answer[:n]) {
+		conn, err := d.DialContext(ctx, "tcp", p.Server)
+		if err != nil {
+			return nil, err
+		}
+		return exchangeStream(ctx, conn, query)
+	}
+	return answer[:n], nil
+}
+
+// TLS is dns over tls, RFC 7858.
+type TLS struct {
+	Server string
+	Config *tls.Config
+}
+
+func (t *TLS) String() string {
+	return "tls://" + t.Server
+}
+
+func (t *TLS) Resolve(ctx context.Context, network, host string) (*Result, error) {
+	return lookup(ctx, network, host, t.String(), t.exchange)
+}
+
+func (t *TLS) exchange(ctx context.Context, query []byte) ([]byte, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	d := tls.Dialer{Config: t.Config}
+	conn, err := d.DialContext(ctx, "tcp", t.Server)
+	if err != nil {
+		return nil, err
+	}
+	return exchangeStream(ctx, conn, query)
+}
+
+// HTTPS is dns over https, RFC 8484.
+type HTTPS struct {
+	URL    string
+	Client *http.Client
+}
+
+func (h *HTTPS) String() string {
+	return h.URL
+}
+
+func (h *HTTPS) Resolve(ctx context.Context, network, host string) (*Result, error) {
+	return lookup(ctx, network, host, h.String(), h.exchange)
+}
+
+func (h *HTTPS) exchange(ctx context.Context, query []byte) ([]byte, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("doh server %s returns %d", h.URL, resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 65535))
+}
@@ -1,17 +1,28 @@
 module github.com/qiniu/httpping
 
-go 1.19
+go 1.23
 
 require (
+	github.com/cespare/xxhash/v2 v2.3.0
 	github.com/grafov/m3u8 v0.11.1
-	github.com/stretchr/testify v1.8.1
+	github.com/quic-go/quic-go v0.54.0
+	github.com/stretchr/testify v1.9.0
 	github.com/yutopp/go-flv v0.2.0
+	golang.org/x/net v0.28.0
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/quic-go/qpack v0.5.1 // indirect
 	github.com/yutopp/go-amf0 v0.0.0-20180803120851-48851794bb1f // indirect
+	go.uber.org/mock v0.5.0 // indirect
+	golang.org/x/crypto v0.26.0 // indirect
+	golang.org/x/mod v0.18.0 // indirect
+	golang.org/x/sync v0.8.0 // indirect
+	golang.org/x/sys v0.23.0 // indirect
+	golang.org/x/text v0.17.0 // indirect
+	golang.org/x/tools v0.22.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,150 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	VerdictPass = "pass"
+	VerdictFail = "fail"
+)
+
+// maxAssertBody is how much of the body is kept for the body assertions.
+const maxAssertBody = 1 << 20
+
+// Assertions turn a ping into a health check, the zero value of a field is not checked.
+// The expected body hash is Pinger.ExpectedHash, a mismatch fails the request,
+// and a body that does not match the hash headers of Pinger.HashAlgo fails too.
+type Assertions struct {
+	Status       []int // any of them
+	Headers      map[string]string
+	HeaderRegex  map[string]*regexp.Regexp
+	BodyContains []string // within the first 1MB of the body
+	BodyRegex    []*regexp.Regexp
+	MaxTtfb      time.Duration
+	MaxTotal     time.Duration
+	MinSpeed     float32 // unit kb/s
+}
+
+func (a *Assertions) needBody() bool {
+	return len(a.BodyContains) != 0 || len(a.BodyRegex) != 0
+}
+
+// limitedBuffer keeps the first bytes written and drops the rest.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if n := b.limit - b.Len(); n > 0 {
+		b.Buffer.Write(p[:minInt(n, len(p))])
+	}
+	return len(p), nil
+}
+
+// bodyWriter is where the body goes besides being counted, nil to discard it.
+func (p *Pinger) bodyWriter(info *Info) io.Writer {
+	var writers []io.Writer
+	if p.BodyHasher != nil {
+		writers = append(writers, p.BodyHasher)
+	}
+	if p.body != nil {
+		writers = append(writers, p.body)
+	}
+	if p.Assertions != nil && p.Assertions.needBody() {
+		info.body = &limitedBuffer{limit: maxAssertBody}
+		writers = append(writers, info.body)
+	}
+	switch len(writers) {
+	case 0:
+		return nil
+	case 1:
+		return writers[0]
+	}
+	return io.MultiWriter(writers...)
+}
+
+func (p *Pinger) check(info *Info) {
+	a := p.Assertions
+	var failed []string
+	fail := func(format string, args ...interface{}) {
+		failed = append(failed, fmt.Sprintf(format, args...))
+	}
+	if info.Error != "" {
+		fail("request failed with %s: %s", info.ErrorCode, info.Error)
+	}
+	if info.Code != 0 {
+		if len(a.Status) != 0 && !containsInt(a.Status, info.Code) {
+			fail("status %d not in %v", info.Code, a.Status)
+		}
+		for k, v := range a.Headers {
+			if got := info.header.Get(k); got != v {
+				fail("header %s is %q, expected %q", k, got, v)
+			}
+		}
+		for k, re := range a.HeaderRegex {
+			if got := info.header.Get(k); !re.MatchString(got) {
+				fail("header %s is %q, does not match %s", k, got, re)
+			}
+		}
+	}
+	if info.body != nil {
+		body := info.body.Bytes()
+		for _, s := range a.BodyContains {
+			if !bytes.Contains(body, []byte(s)) {
+				fail("body does not contain %q", s)
+			}
+		}
+		for _, re := range a.BodyRegex {
+			if !re.Match(body) {
+				fail("body does not match %s", re)
+			}
+		}
+	}
+	for _, c := range info.HashChecks {
+		if !c.Match {
+			fail("body hash %s does not match %s %s", info.Hash, c.Header, c.Expected)
+		}
+	}
+	if info.Error == "" {
+		if a.MaxTtfb > 0 && time.Duration(info.TtfbMs)*time.Millisecond > a.MaxTtfb {
+			fail("ttfb %dms over %s", info.TtfbMs, a.MaxTtfb)
+		}
+		if a.MaxTotal > 0 && time.Duration(info.TotalTimeMs)*time.Millisecond > a.MaxTotal {
+			fail("total time %dms over %s", info.TotalTimeMs, a.MaxTotal)
+		}
+		if a.MinSpeed > 0 && info.Speed < a.MinSpeed {
+			fail("speed %.2fkb/s under %.2fkb/s", info.Speed, a.MinSpeed)
+		}
+	}
+	info.Verdict = VerdictPass
+	if len(failed) != 0 {
+		info.Verdict = VerdictFail
+		info.FailedAssertions = failed
+	}
+}
+
+func containsInt(values []int, v int) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseHeaderAssertion splits "Name: value" like the -H flag of the command.
+func ParseHeaderAssertion(s string) (string, string, error) {
+	k, v, ok := strings.Cut(s, ":")
+	if !ok {
+		return "", "", fmt.Errorf("header assertion %q is not \"Name: value\"", s)
+	}
+	return http.CanonicalHeaderKey(strings.TrimSpace(k)), strings.TrimSpace(v), nil
+}
@@ -0,0 +1,47 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAssertions(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("X-Version", "v1.2.3")
+		w.Write([]byte("status: ok"))
+	}))
+	defer ts.Close()
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+	assert.Nil(t, err)
+	p := Pinger{Req: req, Assertions: &Assertions{
+		Status:       []int{200, 204},
+		Headers:      map[string]string{"Content-Type": "text/plain"},
+		HeaderRegex:  map[string]*regexp.Regexp{"X-Version": regexp.MustCompile(`^v1\.`)},
+		BodyContains: []string{"ok"},
+		BodyRegex:    []*regexp.Regexp{regexp.MustCompile(`status: \w+`)},
+		MaxTtfb:      time.Second,
+		MaxTotal:     time.Second,
+	}}
+	info, err := p.Ping()
+	assert.Nil(t, err)
+	assert.Equal(t, VerdictPass, info.Verdict)
+	assert.Empty(t, info.FailedAssertions)
+
+	p.Assertions = &Assertions{
+		Status:       []int{204},
+		Headers:      map[string]string{"Content-Type": "text/html"},
+		BodyContains: []string{"error"},
+		MinSpeed:     1e9,
+	}
+	info, err = p.Ping()
+	assert.Nil(t, err)
+	assert.Equal(t, VerdictFail, info.Verdict)
+	assert.Len(t, info.FailedAssertions, 4)
+}
@@ -0,0 +1,197 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	CacheHit     = "hit"
+	CacheMiss    = "miss"
+	CacheStale   = "stale"
+	CacheUnknown = "unknown"
+)
+
+// cdnHeaders are kept in CDNInfo.Headers when the response has them.
+var cdnHeaders = []string{
+	"Age", "Via", "Server", "Cache-Control", "X-Cache", "X-Cache-Lookup", "X-Cache-Status", "X-Served-By",
+	"X-Cache-Hits", "CF-Cache-Status", "CF-Ray", "X-Amz-Cf-Pop", "X-Amz-Cf-Id", "X-Qnm-Cache", "X-Reqid", "Eagleid",
+}
+
+type CDNInfo struct {
+	Vendor  string `json:",omitempty"`
+	Cache   string // hit, miss, stale or unknown
+	AgeSec  *int64 `json:",omitempty"` // the Age header, how long the object has been in the cache
+	EdgeId  string `json:",omitempty"`
+	Headers map[string]string
+}
+
+// CacheParser reads the cache status and the edge of one vendor from the
+// response headers, ok is false when the response is not from the vendor.
+type CacheParser func(h http.Header) (cache, edge string, ok bool)
+
+type cdnParser struct {
+	vendor string
+	parse  CacheParser
+}
+
+var (
+	cdnMu      sync.RWMutex
+	cdnParsers []cdnParser
+)
+
+func init() {
+	RegisterCDN("cloudflare", parseCloudflare)
+	RegisterCDN("cloudfront", parseCloudFront)
+	RegisterCDN("fastly", parseFastly)
+	RegisterCDN("akamai", parseAkamai)
+	RegisterCDN("qiniu", parseQiniu)
+}
+
+// RegisterCDN adds a vendor parser, the parsers are tried in the order they
+// were registered and the generic X-Cache, X-Cache-Lookup and Age headers last.
+func RegisterCDN(vendor string, parse CacheParser) {
+	cdnMu.Lock()
+	defer cdnMu.Unlock()
+	cdnParsers = append(cdnParsers, cdnParser{vendor: vendor, parse: parse})
+}
+
+// ParseCDN classifies the response, nil when there are no response headers.
+func ParseCDN(h http.Header) *CDNInfo {
+	if h == nil {
+		return nil
+	}
+	c := &CDNInfo{Cache: CacheUnknown, Headers: map[string]string{}}
+	for _, k := range cdnHeaders {
+		if v := h.Values(k); len(v) != 0 {
+			c.Headers[k] = strings.Join(v, ", ")
+		}
+	}
+	if age, err := strconv.ParseInt(strings.TrimSpace(h.Get("Age")), 10, 64); err == nil {
+		c.AgeSec = &age
+	}
+
+	cdnMu.RLock()
+	parsers := cdnParsers
+	cdnMu.RUnlock()
+	for _, p := range parsers {
+		if cache, edge, ok := p.parse(h); ok {
+			c.Vendor = p.vendor
+			c.Cache = cache
+			c.EdgeId = edge
+			break
+		}
+	}
+	if c.Vendor == "" {
+		c.Cache, c.EdgeId = parseGeneric(h)
+	}
+	if c.Cache == CacheUnknown && c.AgeSec != nil && *c.AgeSec > 0 {
+		// only a cache adds a positive Age
+		c.Cache = CacheHit
+	}
+	return c
+}
+
+// cacheStatus maps the status words of the vendors, like TCP_MEM_HIT,
+// RefreshHit, EXPIRED or UPDATING, to hit, miss or stale.
+func cacheStatus(s string) string {
+	s = strings.ToUpper(s)
+	for _, w := range []string{"STALE", "UPDATING"} {
+		if strings.Contains(s, w) {
+			return CacheStale
+		}
+	}
+	for _, w := range []string{"MISS", "EXPIRED", "BYPASS", "DYNAMIC", "PASS"} {
+		if strings.Contains(s, w) {
+			return CacheMiss
+		}
+	}
+	for _, w := range []string{"HIT", "REVALIDATED"} {
+		if strings.Contains(s, w) {
+			return CacheHit
+		}
+	}
+	return CacheUnknown
+}
+
+// lastValue is the entry added by the cache nearest to the client.
+func lastValue(v string) string {
+	parts := strings.Split(v, ",")
+	return strings.TrimSpace(parts[len(parts)-1])
+}
+
+func parseCloudflare(h http.Header) (string, string, bool) {
+	status := h.Get("CF-Cache-Status")
+	ray := h.Get("CF-Ray")
+	if status == "" && ray == "" {
+		return "", "", false
+	}
+	// the ray id ends with the colo, like 8a1b2c3d4e5f6789-SJC
+	edge := ray
+	if i := strings.LastIndex(ray, "-"); i >= 0 {
+		edge = ray[i+1:]
+	}
+	return cacheStatus(status), edge, true
+}
+
+func parseCloudFront(h http.Header) (string, string, bool) {
+	pop := h.Get("X-Amz-Cf-Pop")
+	xCache := h.Get("X-Cache")
+	if pop == "" && !strings.Contains(strings.ToLower(xCache), "cloudfront") {
+		return "", "", false
+	}
+	return cacheStatus(xCache), pop, true
+}
+
+func parseFastly(h http.Header) (string, string, bool) {
+	servedBy := h.Get("X-Served-By")
+	if !strings.HasPrefix(servedBy, "cache-") {
+		return "", "", false
+	}
+	// with shielding every cache adds itself, the edge is the last one
+	return cacheStatus(lastValue(h.Get("X-Cache"))), lastValue(servedBy), true
+}
+
+func parseAkamai(h http.Header) (string, string, bool) {
+	xCache := h.Get("X-Cache")
+	if !strings.HasPrefix(xCache, "TCP_") {
+		return "", "", false
+	}
+	// TCP_HIT from a23-1-2-3.deploy.akamaitechnologies.com (AkamaiGHost/...)
+	status, from, _ := strings.Cut(xCache, " from ")
+	edge, _, _ := strings.Cut(from, " ")
+	return cacheStatus(status), edge, true
+}
+
+func parseQiniu(h http.Header) (string, string, bool) {
+	status := h.Get("X-Qnm-Cache")
+	if status == "" {
+		return "", "", false
+	}
+	return cacheStatus(status), lastValue(h.Get("Via")), true
+}
+
+// parseGeneric reads the squid and nginx style headers, the edge is the
+// nearest cache of X-Served-By or Via, or the Server.
+func parseGeneric(h http.Header) (string, string) {
+	cache := CacheUnknown
+	for _, k := range []string{"X-Cache-Status", "X-Cache", "X-Cache-Lookup"} {
+		if v := h.Get(k); v != "" {
+			if cache = cacheStatus(lastValue(v)); cache != CacheUnknown {
+				break
+			}
+		}
+	}
+	edge := lastValue(h.Get("X-Served-By"))
+	if edge == "" {
+		if via := h.Values("Via"); len(via) != 0 {
+			edge = lastValue(via[len(via)-1])
+		}
+	}
+	if edge == "" {
+		edge = h.Get("Server")
+	}
+	return cache, edge
+}
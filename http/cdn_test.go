@@ -0,0 +1,80 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCDN(t *testing.T) {
+	cases := []struct {
+		headers map[string]string
+		vendor  string
+		cache   string
+		edge    string
+	}{
+		{map[string]string{"CF-Cache-Status": "HIT", "CF-Ray": "8a1b2c3d4e5f6789-SJC"}, "cloudflare", CacheHit, "SJC"},
+		{map[string]string{"CF-Cache-Status": "UPDATING", "CF-Ray": "8a1b2c3d4e5f6789-NRT"}, "cloudflare", CacheStale, "NRT"},
+		{map[string]string{"X-Cache": "RefreshHit from cloudfront", "X-Amz-Cf-Pop": "HKG62-C1"}, "cloudfront", CacheHit, "HKG62-C1"},
+		{map[string]string{"X-Cache": "HIT, MISS", "X-Served-By": "cache-iad-kiad7000025-IAD, cache-sjc10021-SJC"}, "fastly", CacheMiss, "cache-sjc10021-SJC"},
+		{map[string]string{"X-Cache": "TCP_MEM_HIT from a23-1-2-3.deploy.akamaitechnologies.com (AkamaiGHost/10.0)"}, "akamai", CacheHit, "a23-1-2-3.deploy.akamaitechnologies.com"},
+		{map[string]string{"X-Qnm-Cache": "Miss", "Via": "1.1 xs431:8 (Cdn Cache Server V2.0)"}, "qiniu", CacheMiss, "1.1 xs431:8 (Cdn Cache Server V2.0)"},
+		{map[string]string{"X-Cache-Lookup": "Hit From Upstream", "Server": "tengine"}, "", CacheHit, "tengine"},
+		{map[string]string{"X-Cache-Status": "STALE", "Via": "1.1 edge-1, 1.1 edge-2"}, "", CacheStale, "1.1 edge-2"},
+		{map[string]string{"Age": "30", "Server": "nginx"}, "", CacheHit, "nginx"},
+		{map[string]string{"Server": "nginx"}, "", CacheUnknown, "nginx"},
+	}
+	for _, c := range cases {
+		h := http.Header{}
+		for k, v := range c.headers {
+			h.Set(k, v)
+		}
+		info := ParseCDN(h)
+		assert.Equal(t, c.vendor, info.Vendor, c.headers)
+		assert.Equal(t, c.cache, info.Cache, c.headers)
+		assert.Equal(t, c.edge, info.EdgeId, c.headers)
+		assert.Len(t, info.Headers, len(c.headers))
+	}
+
+	cdnMu.RLock()
+	parsers := cdnParsers
+	cdnMu.RUnlock()
+	t.Cleanup(func() {
+		cdnMu.Lock()
+		cdnParsers = parsers
+		cdnMu.Unlock()
+	})
+	RegisterCDN("test", func(h http.Header) (string, string, bool) {
+		v := h.Get("X-Test-Cache")
+		return cacheStatus(v), "test-edge", v != ""
+	})
+	h := http.Header{"X-Test-Cache": {"hit"}}
+	info := ParseCDN(h)
+	assert.Equal(t, "test", info.Vendor)
+	assert.Equal(t, CacheHit, info.Cache)
+	assert.Len(t, cdnParsers, len(parsers)+1)
+}
+
+func TestPingCDN(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Age", "12")
+		w.Header().Set("X-Cache", "HIT")
+		w.Header().Set("Via", "1.1 edge-7")
+		w.Write([]byte("hello"))
+	}))
+	defer ts.Close()
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+	assert.Nil(t, err)
+	p := Pinger{Req: req, CDN: true}
+	info, err := p.Ping()
+	assert.Nil(t, err)
+	if assert.NotNil(t, info.CDN) {
+		assert.Equal(t, CacheHit, info.CDN.Cache)
+		assert.Equal(t, int64(12), *info.CDN.AgeSec)
+		assert.Equal(t, "1.1 edge-7", info.CDN.EdgeId)
+		assert.Equal(t, "HIT", info.CDN.Headers["X-Cache"])
+	}
+}
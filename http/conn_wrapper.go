@@ -4,54 +4,138 @@ import (
 	"context"
 	"crypto/tls"
 	"net"
-	"strconv"
+	"net/url"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/qiniu/httpping/dns"
 	"github.com/qiniu/httpping/network"
 )
 
+// TcpWrapper is used by the transport goroutines while the Pinger reads it,
+// mu guards the conn and what Read and Write change.
 type TcpWrapper struct {
-	ip           string
-	verifyHost   bool
-	ping         func(addr string)
-	d            *net.TCPConn
-	count        int64
-	lastWrite    time.Time
-	firstRead    *time.Time
-	tlsHandshake time.Duration
-	connectStart time.Time
-	dnsTime      time.Duration
-	tcpHandshake time.Duration
-	remoteAddr   *net.TCPAddr
-	localAddr    string
-	domain       string
-	error        string
-	rounds       []RoundTime
+	mu             sync.Mutex
+	ip             string
+	network        string
+	resolver       dns.Resolver
+	dnsResult      *dns.Result
+	verifyHost     bool
+	tlsConfig      *tls.Config // template from the Pinger, nil only sets InsecureSkipVerify by verifyHost
+	ping           func(addr string)
+	d              *net.TCPConn
+	count          int64
+	written        int64
+	firstWrite     time.Time
+	lastWrite      time.Time
+	firstRead      *time.Time
+	tlsHandshake   time.Duration
+	connectStart   time.Time
+	dnsTime        time.Duration
+	tcpHandshake   time.Duration
+	remoteAddr     *net.TCPAddr
+	localAddr      string
+	domain         string
+	error          string
+	nextProtos     []string
+	dials          int
+	tlsState       *tls.ConnectionState
+	sessionCache   tls.ClientSessionCache
+	tlsErr         error
+	h2             *h2Conn
+	proxy          *url.URL
+	proxyTarget    string
+	proxyNegotiate time.Duration
+	timeouts       Timeouts
+	phase          phaseValue
+	series         byteSeries
+	hostIps        map[string]string
+	closed         bool
+	closedInfo     *network.TCPInfo // taken by Close, the transport closes the conn on Connection: close
+	closedStats    *network.TCPStats
 }
 
 func (t *TcpWrapper) Read(b []byte) (n int, err error) {
-	n, err = t.d.Read(b)
+	n, err = t.conn().Read(b)
+	tm := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	t.count += int64(n)
 	if t.firstRead == nil {
-		tm := time.Now()
 		t.firstRead = &tm
 	}
+	if n > 0 {
+		if t.series.interval <= 0 {
+			t.series.interval = defaultThroughputInterval
+		}
+		t.series.add(tm, n)
+	}
 	return
 }
 
 func (t *TcpWrapper) Write(b []byte) (n int, err error) {
-	n, err = t.d.Write(b)
+	n, err = t.conn().Write(b)
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	t.lastWrite = time.Now()
+	if t.written == 0 {
+		t.firstWrite = t.lastWrite
+	}
+	t.written += int64(n)
 	return
 }
 
 func (t *TcpWrapper) Close() error {
-	if t.d != nil {
-		return t.d.Close()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.d == nil || t.closed {
+		return nil
 	}
-	return nil
+	t.closed = true
+	t.closedInfo, _, _ = network.GetSockoptTCPInfo(t.d)
+	t.closedStats, _ = network.GetTCPStats(t.d)
+	return t.d.Close()
+}
+
+func (t *TcpWrapper) conn() *net.TCPConn {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.d
+}
+
+// received is the bytes read over all the connections.
+func (t *TcpWrapper) received() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.count
+}
+
+// sent is the bytes written on the connection and when the first one was.
+func (t *TcpWrapper) sent() (int64, time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.written, t.firstWrite
+}
+
+func (t *TcpWrapper) throughput() *Throughput {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.series.throughput()
+}
+
+// resetTtfb starts the ttfb of the next request on the same connection.
+func (t *TcpWrapper) resetTtfb() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.firstRead = nil
+}
+
+func (t *TcpWrapper) dialCount() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.dials
 }
 
 func (t *TcpWrapper) TcpHandshake() time.Duration {
@@ -62,45 +146,89 @@ func (t *TcpWrapper) TlsHandshake() time.Duration {
 	return t.tlsHandshake
 }
 
+// Phase is the phase the connection is in, or failed in.
+func (t *TcpWrapper) Phase() string {
+	return t.phase.get()
+}
+
 func (t *TcpWrapper) DnsTime() time.Duration {
 	return t.dnsTime
 }
 
 func (t *TcpWrapper) LocalAddr() net.Addr {
-	return t.d.LocalAddr()
+	return t.conn().LocalAddr()
 }
 
 func (t *TcpWrapper) RemoteAddr() net.Addr {
-	return t.d.RemoteAddr()
+	return t.conn().RemoteAddr()
 }
 
 func (t *TcpWrapper) SetDeadline(tm time.Time) error {
-	return t.d.SetDeadline(tm)
+	return t.conn().SetDeadline(tm)
 }
 
 func (t *TcpWrapper) SetReadDeadline(tm time.Time) error {
-	return t.d.SetReadDeadline(tm)
+	return t.conn().SetReadDeadline(tm)
 }
 
 func (t *TcpWrapper) SetWriteDeadline(tm time.Time) error {
-	return t.d.SetWriteDeadline(tm)
+	return t.conn().SetWriteDeadline(tm)
 }
 
-func (t *TcpWrapper) resolve(addrStr string) error {
-	host, port, err := net.SplitHostPort(addrStr)
-	if t.d == nil && t.ip != "" {
-		if err != nil {
-			return err
+// resolveIp looks up host with resolver unless it is already an ip,
+// ipv4 is preferred when both families are allowed, like net.ResolveTCPAddr.
+func resolveIp(ctx context.Context, resolver dns.Resolver, network, host string) (net.IP, *dns.Result, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip, nil, nil
+	}
+	if resolver == nil {
+		resolver = dns.System{}
+	}
+	ipNetwork := "ip" + strings.TrimPrefix(strings.TrimPrefix(network, "tcp"), "udp")
+	res, err := resolver.Resolve(ctx, ipNetwork, host)
+	if err != nil {
+		return nil, nil, err
+	}
+	var ip net.IP
+	for _, addr := range res.Addrs {
+		i := net.ParseIP(addr)
+		if i == nil {
+			continue
+		}
+		if i.To4() != nil {
+			return i, res, nil
 		}
-		addrStr = net.JoinHostPort(t.ip, port)
+		if ip == nil {
+			ip = i
+		}
+	}
+	if ip == nil {
+		return nil, nil, &net.DNSError{Err: "no suitable address found", Name: host, IsNotFound: true}
+	}
+	return ip, res, nil
+}
+
+func (t *TcpWrapper) resolve(ctx context.Context, addrStr string) error {
+	host, port, err := net.SplitHostPort(addrStr)
+	if err != nil {
+		return err
+	}
+	target := host
+	if ip := t.pinnedIp(host); ip != "" {
+		target = ip
+	}
+	portNum, err := net.LookupPort(t.tcpNetwork(), port)
+	if err != nil {
+		return err
 	}
 	dnsStart := time.Now()
-	addr, err := net.ResolveTCPAddr("tcp", addrStr)
+	ip, res, err := resolveIp(ctx, t.resolver, t.tcpNetwork(), target)
 	if err != nil {
 		return err
 	}
 	t.dnsTime = time.Since(dnsStart)
-	t.remoteAddr = addr
+	t.dnsResult = res
+	t.remoteAddr = &net.TCPAddr{IP: ip, Port: portNum}
 	t.domain = host
 	return nil
 }
@@ -114,35 +242,33 @@ func newPort() int {
 	return int(base + x)
 }
 
-func (t *TcpWrapper) recordPrev() {
-	r := RoundTime{
-		Domain:             t.domain,
-		Ip:                 t.remoteAddr.IP.String(),
-		Port:               t.remoteAddr.Port,
-		DnsTimeMs:          uint32(t.dnsTime.Milliseconds()),
-		ConnectTimeMs:      uint32(t.tcpHandshake.Milliseconds()),
-		TLSHandshakeTimeMs: uint32(t.tlsHandshake.Milliseconds()),
-		TtfbMs:             uint32(t.TTFB().Milliseconds()),
-		TotalSize:          t.count,
-		TotalTimeMs:        time.Now().Sub(t.connectStart).Milliseconds(),
+// pinnedIp is the ip to dial for host, ServerIp only applies to the first dial
+// and the host ips to every hop of the redirect chain.
+func (t *TcpWrapper) pinnedIp(host string) string {
+	if t.conn() == nil && t.ip != "" {
+		return t.ip
 	}
-	t.rounds = append(t.rounds, r)
+	return t.hostIps[host]
 }
 
-func (t *TcpWrapper) connect() (err error) {
+func (t *TcpWrapper) tcpNetwork() string {
+	if t.network == "" {
+		return "tcp"
+	}
+	return t.network
+}
+
+func (t *TcpWrapper) connect(ctx context.Context) (err error) {
 	var localAddr *net.TCPAddr
 	var randAddr = false
 	if t.localAddr != "" {
-		if !strings.Contains(t.localAddr, ":") {
-			localAddr, err = net.ResolveTCPAddr("tcp", t.localAddr+":0")
-			if err != nil {
-				return err
-			}
-		} else {
-			localAddr, err = net.ResolveTCPAddr("tcp", t.localAddr)
-			if err != nil {
-				return err
-			}
+		addr := t.localAddr
+		if _, _, err = net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(addr, "0")
+		}
+		localAddr, err = net.ResolveTCPAddr(t.tcpNetwork(), addr)
+		if err != nil {
+			return err
 		}
 	} else {
 		randAddr = true
@@ -150,19 +276,25 @@ func (t *TcpWrapper) connect() (err error) {
 
 dial:
 	if randAddr {
-		localAddr, err = net.ResolveTCPAddr("tcp", ":"+strconv.Itoa(newPort()))
-		if err != nil {
-			return err
+		// bind the unspecified address of the same family as the server
+		ip := net.IPv4zero
+		if t.remoteAddr.IP.To4() == nil {
+			ip = net.IPv6unspecified
 		}
+		localAddr = &net.TCPAddr{IP: ip, Port: newPort()}
 	}
 
+	timeout := t.timeouts.Connect
+	if timeout <= 0 {
+		timeout = defaultConnectTimeout
+	}
 	dialer := net.Dialer{
-		Timeout:   time.Second,
+		Timeout:   timeout,
 		LocalAddr: localAddr,
 	}
 
 	t.connectStart = time.Now()
-	conn, err := dialer.Dial("tcp", t.remoteAddr.String())
+	conn, err := dialer.DialContext(ctx, t.tcpNetwork(), t.remoteAddr.String())
 	if err != nil {
 		if randAddr && network.IsEADDRINUSE(err) {
 			goto dial
@@ -171,24 +303,51 @@ dial:
 	}
 	t.tcpHandshake = time.Since(t.connectStart)
 	tcpConn, _ := conn.(*net.TCPConn)
+	t.mu.Lock()
+	t.dials++
 	t.d = tcpConn
+	t.closed = false
+	t.mu.Unlock()
 	return nil
 }
 
-func (t *TcpWrapper) Dial(_ context.Context, network, addr string) (conn net.Conn, err error) {
-	if t.d != nil {
-		t.recordPrev()
-		_ = t.d.Close()
+func (t *TcpWrapper) Dial(ctx context.Context, network, addr string) (conn net.Conn, err error) {
+	first := t.conn() == nil
+	if !first {
+		_ = t.Close()
 	}
-	err = t.resolve(addr)
+	t.tlsState = nil
+	t.tlsErr = nil
+	t.tlsHandshake = 0
+	t.phase.set(PhaseDns)
+	dnsCtx, cancel := withTimeout(ctx, t.timeouts.Dns)
+	if t.proxy != nil {
+		err = t.resolveProxy(dnsCtx, addr)
+	} else {
+		err = t.resolve(dnsCtx, addr)
+	}
+	cancel()
 	if err != nil {
 		return nil, err
 	}
-	if t.d == nil && t.ping != nil {
+	if first && t.ping != nil {
 		go t.ping(t.remoteAddr.IP.String())
 	}
+	t.mu.Lock()
 	t.firstRead = nil
-	err = t.connect()
+	t.written = 0
+	t.series = byteSeries{interval: t.series.interval}
+	t.mu.Unlock()
+	t.phase.set(PhaseConnect)
+	err = t.connect(ctx)
+	if err == nil && t.proxy != nil {
+		connectCtx, cancel := withTimeout(ctx, t.timeouts.Connect)
+		err = t.negotiate(connectCtx)
+		cancel()
+	}
+	if err == nil {
+		t.phase.set(PhaseTtfb)
+	}
 	return t, err
 }
 
@@ -197,23 +356,89 @@ func (t *TcpWrapper) DialTLS(ctx context.Context, network, addr string) (conn ne
 	if err != nil {
 		return nil, err
 	}
-	cfg := tls.Config{ServerName: strings.Split(addr, ":")[0], InsecureSkipVerify: !t.verifyHost}
-	cl := tls.Client(td, &cfg)
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{InsecureSkipVerify: !t.verifyHost}
+	if t.tlsConfig != nil {
+		cfg = t.tlsConfig.Clone()
+	}
+	cfg.ServerName = host
+	cfg.NextProtos = t.nextProtos
+	cfg.ClientSessionCache = t.sessionCache
+	cl := tls.Client(td, cfg)
+	t.phase.set(PhaseTLS)
+	tlsCtx, cancel := withTimeout(ctx, t.timeouts.TLS)
+	defer cancel()
 	start := time.Now()
-	err = cl.HandshakeContext(ctx)
+	err = cl.HandshakeContext(tlsCtx)
 	if err != nil {
+		t.tlsErr = err
 		return nil, err
 	}
 	t.tlsHandshake = time.Since(start)
+	t.phase.set(PhaseTtfb)
+	cs := cl.ConnectionState()
+	t.tlsState = &cs
+	t.mu.Lock()
 	t.firstRead = nil //reset for https
+	t.written = 0
+	t.series = byteSeries{interval: t.series.interval}
+	t.mu.Unlock()
 	return cl, nil
 }
 
 func (t *TcpWrapper) TTFB() time.Duration {
+	if t.h2 != nil {
+		if s, ok := t.h2.lastStream(); ok && !s.firstByte.IsZero() {
+			return s.firstByte.Sub(s.sent)
+		}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.firstRead == nil {
+		return 0
+	}
 	return t.firstRead.Sub(t.lastWrite)
 }
 
+// requestEnd is when the last byte of the request was sent,
+// http2 keeps writing control frames while the body downloads.
+func (t *TcpWrapper) requestEnd() time.Time {
+	if t.h2 != nil {
+		if s, ok := t.h2.lastStream(); ok {
+			return s.sent
+		}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.lastWrite
+}
+
+// CommonInfo is the tcp info of the connection, or the last one it had when
+// it is closed already.
 func (t *TcpWrapper) CommonInfo() (*network.TCPInfo, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		if t.closedInfo == nil {
+			return nil, net.ErrClosed
+		}
+		return t.closedInfo, nil
+	}
 	i, _, err := network.GetSockoptTCPInfo(t.d)
 	return i, err
 }
+
+func (t *TcpWrapper) TCPStats() (*network.TCPStats, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		if t.closedStats == nil {
+			return nil, net.ErrClosed
+		}
+		return t.closedStats, nil
+	}
+	return network.GetTCPStats(t.d)
+}
@@ -0,0 +1,169 @@
+package http
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/json"
+	"sync"
+)
+
+// ObjectVersion is what identifies the object a node served.
+type ObjectVersion struct {
+	Hash          string `json:",omitempty"`
+	ContentLength string `json:",omitempty"`
+	ETag          string `json:",omitempty"`
+	LastModified  string `json:",omitempty"`
+}
+
+func (v *ObjectVersion) diff(o *ObjectVersion) []string {
+	var fields []string
+	if v.Hash != o.Hash {
+		fields = append(fields, "Hash")
+	}
+	if v.ContentLength != o.ContentLength {
+		fields = append(fields, "ContentLength")
+	}
+	if v.ETag != o.ETag {
+		fields = append(fields, "ETag")
+	}
+	if v.LastModified != o.LastModified {
+		fields = append(fields, "LastModified")
+	}
+	return fields
+}
+
+type NodeResult struct {
+	Ip     string
+	Origin bool `json:",omitempty"`
+	ObjectVersion
+	Agrees           bool     // same object as the majority, false when the request failed, the origin does not vote
+	Mismatches       []string `json:",omitempty"` // the fields different from the majority
+	OriginMismatches []string `json:",omitempty"` // the fields different from the origin
+	Info             *Info
+}
+
+type ConsistencyResult struct {
+	Domain         string
+	Majority       ObjectVersion
+	Origin         *ObjectVersion `json:",omitempty"`
+	Nodes          []NodeResult
+	Disagree       int // nodes that served another object than the majority, the origin is not counted
+	OriginDisagree int // nodes that served another object than the origin
+	Failed         int
+	Consistent     bool // all the nodes and the origin served the same object
+}
+
+func (r *ConsistencyResult) String() string {
+	t, _ := json.MarshalIndent(r, "", "	")
+	return string(t)
+}
+
+// PingConsistency fetches the url from every ip, all the resolved ips when
+// ips is empty, and from origin when it is not empty, then compares the body
+// hash, Content-Length, ETag and Last-Modified of the responses. The origin
+// is dialed like ServerIp, a host or an ip, the Host header stays the same.
+// The body is hashed with NewHasher, md5 when it is nil.
+func (p *Pinger) PingConsistency(ctx context.Context, ips []string, origin string) (*ConsistencyResult, error) {
+	err := p.normalizeURL()
+	if err != nil {
+		return nil, err
+	}
+	host := p.Req.URL.Hostname()
+	if len(ips) == 0 {
+		ips, _, _, err = p.resolveAll(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+	}
+	q := *p
+	if q.NewHasher == nil {
+		q.NewHasher = md5.New
+		q.HashAlgo = HashMD5
+	}
+
+	r := &ConsistencyResult{Domain: host, Nodes: make([]NodeResult, len(ips))}
+	for i, ip := range ips {
+		r.Nodes[i].Ip = ip
+	}
+	if origin != "" {
+		r.Nodes = append(r.Nodes, NodeResult{Ip: origin, Origin: true})
+	}
+	var wg sync.WaitGroup
+	for i := range r.Nodes {
+		c := q.clone(ctx)
+		c.ServerIp = r.Nodes[i].Ip
+		wg.Add(1)
+		go func(n *NodeResult) {
+			defer wg.Done()
+			info, err := c.Ping()
+			if info == nil {
+				info = &Info{Domain: host, Ip: n.Ip, Error: err.Error()}
+			}
+			n.Info = info
+			if info.header != nil {
+				n.ObjectVersion = ObjectVersion{
+					Hash:          info.Hash,
+					ContentLength: info.header.Get("Content-Length"),
+					ETag:          info.header.Get("ETag"),
+					LastModified:  info.header.Get("Last-Modified"),
+				}
+			}
+		}(&r.Nodes[i])
+	}
+	wg.Wait()
+
+	r.Majority = majority(r.Nodes)
+	for i := range r.Nodes {
+		n := &r.Nodes[i]
+		if n.Info.Error != "" {
+			r.Failed++
+			continue
+		}
+		n.Mismatches = n.diff(&r.Majority)
+		n.Agrees = len(n.Mismatches) == 0
+		if n.Origin {
+			o := n.ObjectVersion
+			r.Origin = &o
+		} else if !n.Agrees {
+			r.Disagree++
+		}
+	}
+	if r.Origin != nil {
+		for i := range r.Nodes {
+			if n := &r.Nodes[i]; !n.Origin && n.Info.Error == "" {
+				n.OriginMismatches = n.diff(r.Origin)
+				if len(n.OriginMismatches) != 0 {
+					r.OriginDisagree++
+				}
+			}
+		}
+	}
+	r.Consistent = r.Disagree == 0 && r.OriginDisagree == 0 && r.Failed == 0
+	return r, nil
+}
+
+// majority is the object most of the successful nodes served, on a tie the
+// one seen first in the order of the nodes. The origin is compared apart.
+func majority(nodes []NodeResult) ObjectVersion {
+	var versions []ObjectVersion
+	counts := map[ObjectVersion]int{}
+	for _, n := range nodes {
+		if n.Info.Error != "" || n.Origin {
+			continue
+		}
+		if counts[n.ObjectVersion] == 0 {
+			versions = append(versions, n.ObjectVersion)
+		}
+		counts[n.ObjectVersion]++
+	}
+	best := -1
+	for i, v := range versions {
+		if best < 0 || counts[v] > counts[versions[best]] {
+			best = i
+		}
+	}
+	if best < 0 {
+		return ObjectVersion{}
+	}
+	return versions[best]
+}
@@ -0,0 +1,64 @@
+package http
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPingConsistency(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		local := r.Context().Value(http.LocalAddrContextKey).(net.Addr).String()
+		if strings.HasPrefix(local, "127.0.0.3:") || strings.HasPrefix(local, "127.0.0.4:") {
+			w.Header().Set("ETag", `"v2"`)
+			w.Write([]byte("hello v2"))
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("hello v1"))
+	}))
+	l, err := net.Listen("tcp4", "0.0.0.0:0")
+	assert.Nil(t, err)
+	ts.Listener = l
+	ts.Start()
+	defer ts.Close()
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+
+	req, err := http.NewRequest(http.MethodGet, "http://cdn.test:"+port+"/file", nil)
+	assert.Nil(t, err)
+	p := Pinger{Req: req}
+	r, err := p.PingConsistency(req.Context(), []string{"127.0.0.1", "127.0.0.2", "127.0.0.3"}, "127.0.0.4")
+	assert.Nil(t, err)
+	assert.False(t, r.Consistent)
+	assert.Equal(t, `"v1"`, r.Majority.ETag)
+	assert.Equal(t, "8", r.Majority.ContentLength)
+	assert.Equal(t, `"v2"`, r.Origin.ETag)
+	assert.Equal(t, 1, r.Disagree)
+	assert.Equal(t, 2, r.OriginDisagree)
+	assert.Len(t, r.Nodes, 4)
+	for _, n := range r.Nodes {
+		switch n.Ip {
+		case "127.0.0.1", "127.0.0.2":
+			assert.True(t, n.Agrees)
+			assert.Equal(t, []string{"Hash", "ETag"}, n.OriginMismatches)
+		case "127.0.0.3":
+			assert.False(t, n.Agrees)
+			assert.Equal(t, []string{"Hash", "ETag"}, n.Mismatches)
+			assert.Empty(t, n.OriginMismatches)
+		default:
+			assert.True(t, n.Origin)
+			assert.False(t, n.Agrees)
+		}
+	}
+
+	// the origin does not break the tie of the nodes
+	r, err = p.PingConsistency(req.Context(), []string{"127.0.0.1", "127.0.0.3"}, "127.0.0.4")
+	assert.Nil(t, err)
+	assert.Equal(t, `"v1"`, r.Majority.ETag)
+	assert.Equal(t, 1, r.Disagree)
+	assert.Equal(t, 1, r.OriginDisagree)
+}
@@ -0,0 +1,85 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+const (
+	FamilyIPv4 = "ipv4"
+	FamilyIPv6 = "ipv6"
+)
+
+// HappyEyeballsDelay is the fallback delay before the ipv4 attempt starts,
+// the same as the default of net.Dialer.
+const HappyEyeballsDelay = 300 * time.Millisecond
+
+type DualStackResult struct {
+	Domain        string
+	IPv4          *Info
+	IPv6          *Info
+	HappyEyeballs string // the family a Happy Eyeballs client would have chosen
+}
+
+func (r *DualStackResult) String() string {
+	t, _ := json.MarshalIndent(r, "", "	")
+	return string(t)
+}
+
+// happyEyeballs follows net.Dialer: it dials after both lookups are done,
+// ipv6 first and ipv4 HappyEyeballsDelay later, the first connected wins.
+func happyEyeballs(v4, v6 *Info) string {
+	ok4 := v4 != nil && v4.Error == "" && v4.Ip != ""
+	ok6 := v6 != nil && v6.Error == "" && v6.Ip != ""
+	switch {
+	case ok4 && !ok6:
+		return FamilyIPv4
+	case ok6 && !ok4:
+		return FamilyIPv6
+	case !ok4 && !ok6:
+		return ""
+	}
+	start := time.Duration(v4.DnsTimeMs) * time.Millisecond
+	if dns6 := time.Duration(v6.DnsTimeMs) * time.Millisecond; dns6 > start {
+		start = dns6
+	}
+	done6 := start + time.Duration(v6.ConnectTimeMs)*time.Millisecond
+	done4 := start + HappyEyeballsDelay + time.Duration(v4.ConnectTimeMs)*time.Millisecond
+	if done4 < done6 {
+		return FamilyIPv4
+	}
+	return FamilyIPv6
+}
+
+// PingDualStack probes the ipv4 and the ipv6 address of the domain concurrently,
+// each family does its own A or AAAA lookup.
+func (p *Pinger) PingDualStack(ctx context.Context) (*DualStackResult, error) {
+	err := p.normalizeURL()
+	if err != nil {
+		return nil, err
+	}
+	r := &DualStackResult{Domain: p.Req.URL.Hostname()}
+
+	var wg sync.WaitGroup
+	probe := func(network string, info **Info) {
+		q := p.clone(ctx)
+		q.Network = network
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			i, err := q.Ping()
+			if i == nil {
+				i = &Info{Domain: r.Domain, Error: err.Error()}
+			}
+			*info = i
+		}()
+	}
+	probe("tcp4", &r.IPv4)
+	probe("tcp6", &r.IPv6)
+	wg.Wait()
+
+	r.HappyEyeballs = happyEyeballs(r.IPv4, r.IPv6)
+	return r, nil
+}
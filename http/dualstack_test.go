@@ -0,0 +1,93 @@
+package http
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/qiniu/httpping/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHappyEyeballs(t *testing.T) {
+	v4 := &Info{Ip: "1.1.1.1", DnsTimeMs: 10, ConnectTimeMs: 20}
+	v6 := &Info{Ip: "2400::1", DnsTimeMs: 30, ConnectTimeMs: 200}
+	assert.Equal(t, FamilyIPv6, happyEyeballs(v4, v6))
+	v6.ConnectTimeMs = 400
+	assert.Equal(t, FamilyIPv4, happyEyeballs(v4, v6))
+	v4.Error = "connection refused"
+	assert.Equal(t, FamilyIPv6, happyEyeballs(v4, v6))
+	assert.Equal(t, "", happyEyeballs(v4, &Info{}))
+}
+
+// familyResolver answers the A or AAAA lookups from its map.
+type familyResolver map[string][]string
+
+func (f familyResolver) Resolve(ctx context.Context, network, host string) (*dns.Result, error) {
+	addrs := f[network]
+	if len(addrs) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return &dns.Result{Resolver: f.String(), Addrs: addrs}, nil
+}
+
+func (f familyResolver) String() string {
+	return "family"
+}
+
+// listenDualStack listens on 127.0.0.1 and ::1 with the same port.
+func listenDualStack(t *testing.T) (net.Listener, net.Listener) {
+	for i := 0; i < 10; i++ {
+		l4, err := net.Listen("tcp4", "127.0.0.1:0")
+		if err != nil {
+			t.Skip("no ipv4 loopback:", err)
+		}
+		_, port, _ := net.SplitHostPort(l4.Addr().String())
+		l6, err := net.Listen("tcp6", "[::1]:"+port)
+		if err == nil {
+			return l4, l6
+		}
+		l4.Close()
+		l, err := net.Listen("tcp6", "[::1]:0")
+		if err != nil {
+			t.Skip("no ipv6 loopback:", err)
+		}
+		l.Close()
+	}
+	t.Skip("no port free on both loopbacks")
+	return nil, nil
+}
+
+func TestPingDualStack(t *testing.T) {
+	l4, l6 := listenDualStack(t)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Context().Value(http.LocalAddrContextKey).(net.Addr).String()))
+	})}
+	go server.Serve(l4)
+	go server.Serve(l6)
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(l4.Addr().String())
+
+	req, err := http.NewRequest(http.MethodGet, "http://dual.test:"+port+"/", nil)
+	assert.Nil(t, err)
+	p := Pinger{Req: req, Resolver: familyResolver{"ip4": {"127.0.0.1"}, "ip6": {"::1"}}}
+	r, err := p.PingDualStack(req.Context())
+	assert.Nil(t, err)
+	assert.Equal(t, "dual.test", r.Domain)
+	assert.Empty(t, r.IPv4.Error)
+	assert.Equal(t, "127.0.0.1", r.IPv4.Ip)
+	assert.Equal(t, 200, r.IPv4.Code)
+	assert.Empty(t, r.IPv6.Error)
+	assert.Equal(t, "::1", r.IPv6.Ip)
+	assert.Equal(t, 200, r.IPv6.Code)
+	// both connect at once on loopback, the ipv4 delay lets ipv6 win
+	assert.Equal(t, FamilyIPv6, r.HappyEyeballs)
+
+	p.Resolver = familyResolver{"ip4": {"127.0.0.1"}}
+	r, err = p.PingDualStack(req.Context())
+	assert.Nil(t, err)
+	assert.Empty(t, r.IPv4.Error)
+	assert.NotEmpty(t, r.IPv6.Error)
+	assert.Equal(t, FamilyIPv4, r.HappyEyeballs)
+}
@@ -0,0 +1,148 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+
+	"github.com/qiniu/httpping/dns"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// ErrorCode values, timeouts are named after the phase, like ttfb_timeout.
+const (
+	ErrDnsNXDomain    = "dns_nxdomain"
+	ErrDnsServFail    = "dns_servfail"
+	ErrDnsRefused     = "dns_refused"
+	ErrDnsTimeout     = "dns_timeout"
+	ErrDns            = "dns_error"
+	ErrConnRefused    = "conn_refused"
+	ErrConnReset      = "conn_reset"
+	ErrConnTimeout    = "conn_timeout"
+	ErrConn           = "conn_error"
+	ErrTLSVerify      = "tls_verify"
+	ErrTLSPinMismatch = "tls_pin_mismatch"
+	ErrTLSHandshake   = "tls_handshake"
+	ErrTLSTimeout     = "tls_timeout"
+	ErrTtfbTimeout    = "ttfb_timeout"
+	ErrBodyTimeout    = "body_timeout"
+	ErrBodyTruncated  = "body_truncated"
+	ErrHashMismatch   = "hash_mismatch"
+	ErrHTTPClientSide = "http_4xx"
+	ErrHTTPServerSide = "http_5xx"
+	ErrUnknown        = "unknown"
+)
+
+// PhaseHTTP is the phase of the errors reported by the status code.
+const PhaseHTTP = "http"
+
+var timeoutCodes = map[string]string{
+	PhaseDns:     ErrDnsTimeout,
+	PhaseConnect: ErrConnTimeout,
+	PhaseTLS:     ErrTLSTimeout,
+	PhaseTtfb:    ErrTtfbTimeout,
+	PhaseBody:    ErrBodyTimeout,
+}
+
+// ProbeError is returned with the Info when the probe itself failed.
+type ProbeError struct {
+	Code  string
+	Phase string
+	Err   error
+}
+
+func (e *ProbeError) Error() string {
+	return fmt.Sprintf("%s in %s: %v", e.Code, e.Phase, e.Err)
+}
+
+func (e *ProbeError) Unwrap() error {
+	return e.Err
+}
+
+type HashMismatchError struct {
+	Expected string
+	Got      string
+}
+
+func (e *HashMismatchError) Error() string {
+	return fmt.Sprintf("body hash %s, expected %s", e.Got, e.Expected)
+}
+
+// ClassifyError gives the ErrorCode of err that happened in phase.
+func ClassifyError(err error, phase string) string {
+	return classify(context.Background(), err, phase)
+}
+
+func classify(ctx context.Context, err error, phase string) string {
+	var rcodeErr *dns.RcodeError
+	var dnsErr *net.DNSError
+	var hashErr *HashMismatchError
+	var certErr *tls.CertificateVerificationError
+	var pinErr *PinError
+	var unknownAuthErr x509.UnknownAuthorityError
+	var hostErr x509.HostnameError
+	var alertErr tls.AlertError
+	var recordErr tls.RecordHeaderError
+	switch {
+	case errors.As(err, &hashErr):
+		return ErrHashMismatch
+	case errors.As(err, &rcodeErr):
+		switch rcodeErr.RCode {
+		case dnsmessage.RCodeNameError:
+			return ErrDnsNXDomain
+		case dnsmessage.RCodeServerFailure:
+			return ErrDnsServFail
+		case dnsmessage.RCodeRefused:
+			return ErrDnsRefused
+		}
+		return ErrDns
+	case isTimeout(ctx, err):
+		if code, ok := timeoutCodes[phase]; ok {
+			return code
+		}
+		return ErrConnTimeout
+	case errors.As(err, &dnsErr):
+		if dnsErr.IsNotFound {
+			return ErrDnsNXDomain
+		}
+		if dnsErr.Err == "server misbehaving" {
+			return ErrDnsServFail
+		}
+		return ErrDns
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ErrConnRefused
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE):
+		return ErrConnReset
+	case errors.As(err, &pinErr):
+		return ErrTLSPinMismatch
+	case errors.As(err, &certErr), errors.As(err, &unknownAuthErr), errors.As(err, &hostErr):
+		return ErrTLSVerify
+	case errors.As(err, &alertErr), errors.As(err, &recordErr):
+		return ErrTLSHandshake
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return ErrBodyTruncated
+	}
+	switch phase {
+	case PhaseDns:
+		return ErrDns
+	case PhaseConnect:
+		return ErrConn
+	case PhaseTLS:
+		return ErrTLSHandshake
+	case PhaseBody:
+		if errors.Is(err, io.EOF) {
+			return ErrBodyTruncated
+		}
+	}
+	return ErrUnknown
+}
+
+// StatusErrorCode is http_4xx or http_5xx, the other classes are named the same way.
+func StatusErrorCode(code int) string {
+	return fmt.Sprintf("http_%dxx", code/100)
+}
@@ -0,0 +1,88 @@
+package http
+
+import (
+	"crypto/md5"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorCode(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		case "/truncated":
+			w.Header().Set("Content-Length", "100")
+			w.Write([]byte("hello"))
+		default:
+			w.Write([]byte("hello"))
+		}
+	}))
+	defer ts.Close()
+
+	ping := func(url string) (*Pinger, *Info, error) {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		assert.Nil(t, err)
+		p := &Pinger{Req: req}
+		info, err := p.Ping()
+		return p, info, err
+	}
+
+	_, info, err := ping(ts.URL + "/missing")
+	assert.Nil(t, err)
+	assert.Equal(t, ErrHTTPClientSide, info.ErrorCode)
+	assert.Equal(t, PhaseHTTP, info.ErrorPhase)
+
+	_, info, err = ping(ts.URL + "/truncated")
+	assert.NotNil(t, err)
+	assert.Equal(t, ErrBodyTruncated, info.ErrorCode)
+	assert.Equal(t, PhaseBody, info.ErrorPhase)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	closed := l.Addr().String()
+	l.Close()
+	_, info, err = ping("http://" + closed)
+	assert.NotNil(t, err)
+	assert.Equal(t, ErrConnRefused, info.ErrorCode)
+	assert.Equal(t, PhaseConnect, info.ErrorPhase)
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+	assert.Nil(t, err)
+	p := &Pinger{Req: req, BodyHasher: md5.New(), ExpectedHash: "5d41402abc4b2a76b9719d911017c592"}
+	info, err = p.Ping()
+	assert.Nil(t, err)
+	p.BodyHasher.Reset()
+	p.ExpectedHash = "00"
+	info, err = p.Ping()
+	pe, ok := err.(*ProbeError)
+	assert.True(t, ok)
+	assert.Equal(t, ErrHashMismatch, pe.Code)
+	assert.Equal(t, ErrHashMismatch, info.ErrorCode)
+
+	p.BodyHasher = nil
+	info, err = p.Ping()
+	assert.Nil(t, info)
+	assert.Contains(t, err.Error(), "no hasher")
+}
+
+func TestConnectionClose(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Connection", "close")
+		w.Write([]byte("hello"))
+	}))
+	defer ts.Close()
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+	assert.Nil(t, err)
+	p := &Pinger{Req: req}
+	info, err := p.Ping()
+	assert.Nil(t, err)
+	assert.Equal(t, 200, info.Code)
+	assert.Empty(t, info.Error)
+	assert.Empty(t, info.ErrorCode)
+}
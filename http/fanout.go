@@ -0,0 +1,116 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qiniu/httpping/dns"
+)
+
+type IpResult struct {
+	Ip   string
+	Rank int
+	Info *Info
+}
+
+type FanoutResult struct {
+	Domain    string
+	DnsTimeMs uint32
+	Dns       *dns.Result
+	Ips       []IpResult
+}
+
+func (r *FanoutResult) String() string {
+	t, _ := json.MarshalIndent(r, "", "	")
+	return string(t)
+}
+
+// clone returns a Pinger that can run concurrently with p, it gets its own
+// request and a fresh BodyHasher from NewHasher.
+func (p *Pinger) clone(ctx context.Context) *Pinger {
+	q := *p
+	q.Req = p.Req.Clone(ctx)
+	q.BodyHasher = nil
+	if p.NewHasher != nil {
+		q.BodyHasher = p.NewHasher()
+	}
+	return &q
+}
+
+// rankLess orders the successful probes by total time, then by ttfb,
+// the failed ones go last.
+func rankLess(a, b *Info) bool {
+	if succeeded(a) != succeeded(b) {
+		return succeeded(a)
+	}
+	if a.TotalTimeMs != b.TotalTimeMs {
+		return a.TotalTimeMs < b.TotalTimeMs
+	}
+	return a.TtfbMs < b.TtfbMs
+}
+
+// resolveAll returns all the A/AAAA records of host, or host when it is an ip.
+func (p *Pinger) resolveAll(ctx context.Context, host string) ([]string, *dns.Result, time.Duration, error) {
+	if net.ParseIP(host) != nil {
+		return []string{host}, nil, 0, nil
+	}
+	resolver := p.Resolver
+	if resolver == nil {
+		resolver = dns.System{}
+	}
+	dnsStart := time.Now()
+	res, err := resolver.Resolve(ctx, "ip"+strings.TrimPrefix(p.Network, "tcp"), host)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	return res.Addrs, res, time.Since(dnsStart), nil
+}
+
+// PingAllIps resolves all A/AAAA records of the domain and probes every ip
+// in parallel, the Host header and SNI still use the domain.
+func (p *Pinger) PingAllIps(ctx context.Context) (*FanoutResult, error) {
+	err := p.normalizeURL()
+	if err != nil {
+		return nil, err
+	}
+	host := p.Req.URL.Hostname()
+	r := &FanoutResult{Domain: host}
+
+	addrs, res, dnsTime, err := p.resolveAll(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	r.DnsTimeMs = uint32(dnsTime.Milliseconds())
+	r.Dns = res
+
+	r.Ips = make([]IpResult, len(addrs))
+	var wg sync.WaitGroup
+	for i, addr := range addrs {
+		q := p.clone(ctx)
+		q.ServerIp = addr
+		r.Ips[i].Ip = q.ServerIp
+		wg.Add(1)
+		go func(res *IpResult) {
+			defer wg.Done()
+			info, err := q.Ping()
+			if info == nil {
+				info = &Info{Domain: host, Ip: res.Ip, Error: err.Error()}
+			}
+			res.Info = info
+		}(&r.Ips[i])
+	}
+	wg.Wait()
+
+	sort.SliceStable(r.Ips, func(i, j int) bool {
+		return rankLess(r.Ips[i].Info, r.Ips[j].Info)
+	})
+	for i := range r.Ips {
+		r.Ips[i].Rank = i + 1
+	}
+	return r, nil
+}
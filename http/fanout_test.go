@@ -0,0 +1,89 @@
+package http
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qiniu/httpping/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubResolver answers every host with its addrs, or fails with err.
+type stubResolver struct {
+	addrs []string
+	err   error
+	delay time.Duration
+}
+
+func (s stubResolver) Resolve(ctx context.Context, network, host string) (*dns.Result, error) {
+	select {
+	case <-time.After(s.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &dns.Result{Resolver: s.String(), Addrs: s.addrs}, nil
+}
+
+func (s stubResolver) String() string {
+	return "stub"
+}
+
+func TestPingAllIps(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		local := r.Context().Value(http.LocalAddrContextKey).(net.Addr).String()
+		switch {
+		case strings.HasPrefix(local, "127.0.0.1:"):
+			time.Sleep(100 * time.Millisecond)
+		case strings.HasPrefix(local, "127.0.0.3:"):
+			// drop the connection without a response
+			c, _, _ := w.(http.Hijacker).Hijack()
+			c.Close()
+			return
+		}
+		w.Write([]byte(r.Host))
+	}))
+	l, err := net.Listen("tcp4", "0.0.0.0:0")
+	assert.Nil(t, err)
+	ts.Listener = l
+	ts.Start()
+	defer ts.Close()
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+
+	req, err := http.NewRequest(http.MethodGet, "http://fanout.test:"+port+"/", nil)
+	assert.Nil(t, err)
+	p := Pinger{Req: req, NewHasher: md5.New, Resolver: stubResolver{addrs: []string{"127.0.0.3", "127.0.0.1", "127.0.0.2"}}}
+	sum := md5.Sum([]byte("fanout.test:" + port))
+	host := hex.EncodeToString(sum[:])
+	r, err := p.PingAllIps(req.Context())
+	assert.Nil(t, err)
+	assert.Equal(t, "fanout.test", r.Domain)
+	assert.Equal(t, "stub", r.Dns.Resolver)
+	if !assert.Len(t, r.Ips, 3) {
+		return
+	}
+	for i, ip := range []string{"127.0.0.2", "127.0.0.1", "127.0.0.3"} {
+		res := r.Ips[i]
+		assert.Equal(t, ip, res.Ip)
+		assert.Equal(t, i+1, res.Rank)
+		assert.Equal(t, ip, res.Info.Ip)
+		if ip == "127.0.0.3" {
+			assert.NotEmpty(t, res.Info.Error)
+			continue
+		}
+		assert.Empty(t, res.Info.Error)
+		assert.Equal(t, 200, res.Info.Code)
+		// the Host stays the domain on every ip
+		assert.Equal(t, host, res.Info.Hash)
+	}
+	assert.Greater(t, r.Ips[1].Info.TotalTimeMs, r.Ips[0].Info.TotalTimeMs)
+}
@@ -0,0 +1,264 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+const (
+	ProtoHTTP1 = "http1.1"
+	ProtoHTTP2 = "h2"
+)
+
+type H2Stream struct {
+	Id     uint32
+	TtfbMs uint32
+}
+
+type H2Info struct {
+	ALPN           string
+	Fallback       bool
+	FallbackReason string
+	SettingsRttMs  uint32
+	Streams        []H2Stream
+}
+
+// frameParser follows the http2 frame boundaries of one direction of a connection
+// without buffering the payloads.
+type frameParser struct {
+	preface int
+	hdr     [9]byte
+	hdrLen  int
+	skip    int
+	onFrame func(typ http2.FrameType, flags http2.Flags, stream uint32)
+}
+
+func (f *frameParser) feed(b []byte) {
+	for len(b) > 0 {
+		if f.preface > 0 {
+			n := minInt(f.preface, len(b))
+			f.preface -= n
+			b = b[n:]
+			continue
+		}
+		if f.skip > 0 {
+			n := minInt(f.skip, len(b))
+			f.skip -= n
+			b = b[n:]
+			continue
+		}
+		n := copy(f.hdr[f.hdrLen:], b)
+		f.hdrLen += n
+		b = b[n:]
+		if f.hdrLen < len(f.hdr) {
+			return
+		}
+		f.hdrLen = 0
+		f.skip = int(f.hdr[0])<<16 | int(f.hdr[1])<<8 | int(f.hdr[2])
+		stream := binary.BigEndian.Uint32(f.hdr[5:]) & 0x7fffffff
+		f.onFrame(http2.FrameType(f.hdr[3]), http2.Flags(f.hdr[4]), stream)
+	}
+}
+
+type h2Stream struct {
+	id        uint32
+	sent      time.Time
+	firstByte time.Time
+}
+
+// h2Conn sits between the tls connection and the http2 client connection
+// and records when the SETTINGS and the frames of every stream pass by.
+type h2Conn struct {
+	net.Conn
+	mu            sync.Mutex
+	out           frameParser
+	in            frameParser
+	now           time.Time
+	settingsSent  time.Time
+	settingsAcked time.Time
+	streams       []*h2Stream
+}
+
+func newH2Conn(c net.Conn) *h2Conn {
+	h := &h2Conn{Conn: c}
+	h.out = frameParser{preface: len(http2.ClientPreface), onFrame: h.onWrite}
+	h.in = frameParser{onFrame: h.onRead}
+	return h
+}
+
+func (h *h2Conn) stream(id uint32) *h2Stream {
+	for _, s := range h.streams {
+		if s.id == id {
+			return s
+		}
+	}
+	return nil
+}
+
+func (h *h2Conn) onWrite(typ http2.FrameType, flags http2.Flags, id uint32) {
+	switch typ {
+	case http2.FrameSettings:
+		if !flags.Has(http2.FlagSettingsAck) && h.settingsSent.IsZero() {
+			h.settingsSent = h.now
+		}
+	case http2.FrameHeaders:
+		if h.stream(id) == nil {
+			h.streams = append(h.streams, &h2Stream{id: id, sent: h.now})
+		}
+	case http2.FrameData:
+		if s := h.stream(id); s != nil && s.firstByte.IsZero() {
+			// request body, the request ends with its last frame
+			s.sent = h.now
+		}
+	}
+}
+
+func (h *h2Conn) onRead(typ http2.FrameType, flags http2.Flags, id uint32) {
+	switch typ {
+	case http2.FrameSettings:
+		if flags.Has(http2.FlagSettingsAck) && h.settingsAcked.IsZero() {
+			h.settingsAcked = h.now
+		}
+	case http2.FrameHeaders, http2.FrameData:
+		if s := h.stream(id); s != nil && s.firstByte.IsZero() {
+			s.firstByte = h.now
+		}
+	}
+}
+
+func (h *h2Conn) Read(b []byte) (n int, err error) {
+	n, err = h.Conn.Read(b)
+	h.mu.Lock()
+	h.now = time.Now()
+	h.in.feed(b[:n])
+	h.mu.Unlock()
+	return
+}
+
+func (h *h2Conn) Write(b []byte) (n int, err error) {
+	n, err = h.Conn.Write(b)
+	h.mu.Lock()
+	h.now = time.Now()
+	h.out.feed(b[:n])
+	h.mu.Unlock()
+	return
+}
+
+// lastStream is a copy, the read loop keeps updating the streams.
+func (h *h2Conn) lastStream() (h2Stream, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.streams) == 0 {
+		return h2Stream{}, false
+	}
+	return *h.streams[len(h.streams)-1], true
+}
+
+func (h *h2Conn) fill(info *H2Info) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.settingsAcked.IsZero() {
+		info.SettingsRttMs = uint32(h.settingsAcked.Sub(h.settingsSent).Milliseconds())
+	}
+	for _, s := range h.streams {
+		st := H2Stream{Id: s.id}
+		if !s.firstByte.IsZero() {
+			st.TtfbMs = uint32(s.firstByte.Sub(s.sent).Milliseconds())
+		}
+		info.Streams = append(info.Streams, st)
+	}
+}
+
+// h2Transport dials with ALPN h2 itself, speaks http2 when the server agrees
+// and falls back to HTTP/1.1 over the same connection when it refuses.
+type h2Transport struct {
+	w       *TcpWrapper
+	t1      *http.Transport
+	t2      *http2.Transport
+	addr    string
+	cc      *http2.ClientConn
+	conn    *h2Conn
+	pending net.Conn
+	info    H2Info
+}
+
+func newH2Transport(w *TcpWrapper) *h2Transport {
+	t := &h2Transport{w: w, t2: &http2.Transport{}}
+	t.t1 = &http.Transport{DialContext: w.Dial, DialTLSContext: t.dialTLS}
+	w.nextProtos = []string{http2.NextProtoTLS, "http/1.1"}
+	return t
+}
+
+func (t *h2Transport) dialTLS(ctx context.Context, network, addr string) (net.Conn, error) {
+	if c := t.pending; c != nil {
+		t.pending = nil
+		return c, nil
+	}
+	return t.w.DialTLS(ctx, network, addr)
+}
+
+func (t *h2Transport) fallback(reason string) {
+	t.info.Fallback = true
+	t.info.FallbackReason = reason
+	t.w.h2 = nil
+}
+
+func (t *h2Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "https" {
+		t.fallback("h2 requires https")
+		return t.t1.RoundTrip(req)
+	}
+	addr := canonicalAddr(req.URL)
+	if t.cc != nil && t.addr == addr && t.cc.CanTakeNewRequest() {
+		return t.cc.RoundTrip(req)
+	}
+
+	c, err := t.w.DialTLS(req.Context(), "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	t.info.ALPN = c.(*tls.Conn).ConnectionState().NegotiatedProtocol
+	if t.info.ALPN != http2.NextProtoTLS {
+		t.fallback("server refused h2 in ALPN")
+		t.pending = c
+		return t.t1.RoundTrip(req)
+	}
+
+	t.info.Fallback = false
+	t.info.FallbackReason = ""
+	t.conn = newH2Conn(c)
+	t.cc, err = t.t2.NewClientConn(t.conn)
+	if err != nil {
+		return nil, err
+	}
+	t.addr = addr
+	t.w.h2 = t.conn
+	return t.cc.RoundTrip(req)
+}
+
+func (t *h2Transport) h2Info() *H2Info {
+	info := t.info
+	if t.conn != nil && !info.Fallback {
+		t.conn.fill(&info)
+	}
+	return &info
+}
+
+func canonicalAddr(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
@@ -0,0 +1,44 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func pingH2(t *testing.T, enableHTTP2 bool) *Info {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, 64*1024))
+	}))
+	ts.EnableHTTP2 = enableHTTP2
+	ts.StartTLS()
+	defer ts.Close()
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+	assert.Nil(t, err)
+	p := Pinger{Req: req, Proto: ProtoHTTP2}
+	info, err := p.Ping()
+	assert.Nil(t, err)
+	assert.Equal(t, "", info.Error)
+	assert.Equal(t, 200, info.Code)
+	return info
+}
+
+func TestHttp2(t *testing.T) {
+	info := pingH2(t, true)
+	assert.Equal(t, "HTTP/2.0", info.Proto)
+	assert.Equal(t, "h2", info.H2.ALPN)
+	assert.False(t, info.H2.Fallback)
+	assert.Len(t, info.H2.Streams, 1)
+	assert.Equal(t, uint32(1), info.H2.Streams[0].Id)
+	assert.True(t, info.TotalSize > 64*1024)
+}
+
+func TestHttp2Fallback(t *testing.T) {
+	info := pingH2(t, false)
+	assert.Equal(t, "HTTP/1.1", info.Proto)
+	assert.True(t, info.H2.Fallback)
+	assert.NotEmpty(t, info.H2.FallbackReason)
+}
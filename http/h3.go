@@ -0,0 +1,256 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/quic-go/quic-go/logging"
+)
+
+const ProtoHTTP3 = "h3"
+
+var (
+	ErrH3Proxy    = errors.New("h3 does not go through a tcp proxy")
+	ErrH3Redirect = errors.New("h3 does not follow redirects")
+)
+
+type H3Info struct {
+	Version         string
+	HandshakeTimeMs uint32
+	Used0RTT        bool
+	// the packets the client sent and those quic declared lost, on a download
+	// they are mostly acks, the loss of the download itself is not known
+	SentPackets     uint32
+	SentLostPackets uint32
+	SentLossPct     float32
+}
+
+// quicStats collects the connection statistics from the quic tracer callbacks.
+type quicStats struct {
+	mu            sync.Mutex
+	version       logging.Version
+	sentPackets   uint32
+	lostPackets   uint32
+	receivedBytes int64
+	rtt           time.Duration
+	rttVar        time.Duration
+}
+
+func (s *quicStats) tracer(context.Context, logging.Perspective, quic.ConnectionID) *logging.ConnectionTracer {
+	return &logging.ConnectionTracer{
+		NegotiatedVersion: func(chosen logging.Version, _, _ []logging.Version) {
+			s.mu.Lock()
+			s.version = chosen
+			s.mu.Unlock()
+		},
+		SentLongHeaderPacket: func(*logging.ExtendedHeader, logging.ByteCount, logging.ECN, *logging.AckFrame, []logging.Frame) {
+			s.mu.Lock()
+			s.sentPackets++
+			s.mu.Unlock()
+		},
+		SentShortHeaderPacket: func(*logging.ShortHeader, logging.ByteCount, logging.ECN, *logging.AckFrame, []logging.Frame) {
+			s.mu.Lock()
+			s.sentPackets++
+			s.mu.Unlock()
+		},
+		ReceivedLongHeaderPacket: func(_ *logging.ExtendedHeader, size logging.ByteCount, _ logging.ECN, _ []logging.Frame) {
+			s.mu.Lock()
+			s.receivedBytes += int64(size)
+			s.mu.Unlock()
+		},
+		ReceivedShortHeaderPacket: func(_ *logging.ShortHeader, size logging.ByteCount, _ logging.ECN, _ []logging.Frame) {
+			s.mu.Lock()
+			s.receivedBytes += int64(size)
+			s.mu.Unlock()
+		},
+		LostPacket: func(logging.EncryptionLevel, logging.PacketNumber, logging.PacketLossReason) {
+			s.mu.Lock()
+			s.lostPackets++
+			s.mu.Unlock()
+		},
+		UpdatedMetrics: func(rttStats *logging.RTTStats, _, _ logging.ByteCount, _ int) {
+			s.mu.Lock()
+			s.rtt = rttStats.SmoothedRTT()
+			s.rttVar = rttStats.MeanDeviation()
+			s.mu.Unlock()
+		},
+	}
+}
+
+// doH3 does the request over QUIC, like over tcp every ping does a full
+// handshake unless PingResume set the session cache.
+func (p *Pinger) doH3(ctx context.Context, httpInfo *Info, ping func(addr string)) error {
+	if p.Proxy != nil {
+		setError(httpInfo, ctx, ErrH3Proxy, PhaseConnect)
+		return ErrH3Proxy
+	}
+	if p.Redirect {
+		// every dial goes to the target of the first request
+		setError(httpInfo, ctx, ErrH3Redirect, PhaseConnect)
+		return ErrH3Redirect
+	}
+	u := p.Req.URL
+	host := u.Hostname()
+	port := u.Port()
+	if port == "" {
+		port = "443"
+	}
+	target := host
+	if p.ServerIp != "" {
+		target = p.ServerIp
+	} else if ip := p.HostIps[host]; ip != "" {
+		target = ip
+	}
+	httpInfo.Domain = host
+
+	udpNetwork := "udp" + strings.TrimPrefix(p.Network, "tcp")
+	portNum, err := net.LookupPort(udpNetwork, port)
+	if err != nil {
+		setError(httpInfo, ctx, err, PhaseDns)
+		return err
+	}
+	var phase phaseValue
+	phase.set(PhaseDns)
+	dnsCtx, cancel := withTimeout(ctx, p.Timeouts.Dns)
+	dnsStart := time.Now()
+	ip, res, err := resolveIp(dnsCtx, p.Resolver, udpNetwork, target)
+	cancel()
+	if err != nil {
+		setError(httpInfo, dnsCtx, err, phase.get())
+		return err
+	}
+	addr := &net.UDPAddr{IP: ip, Port: portNum}
+	httpInfo.DnsTimeMs = uint32(time.Since(dnsStart).Milliseconds())
+	httpInfo.Dns = res
+	httpInfo.Ip = addr.IP.String()
+	httpInfo.Port = addr.Port
+	if ping != nil {
+		go ping(httpInfo.Ip)
+	}
+
+	var localAddr *net.UDPAddr
+	if p.SrcAddr != "" {
+		src := p.SrcAddr
+		if _, _, err = net.SplitHostPort(src); err != nil {
+			src = net.JoinHostPort(src, "0")
+		}
+		localAddr, err = net.ResolveUDPAddr(udpNetwork, src)
+		if err != nil {
+			setError(httpInfo, ctx, err, PhaseConnect)
+			return err
+		}
+	}
+	udpConn, err := net.ListenUDP(udpNetwork, localAddr)
+	if err != nil {
+		setError(httpInfo, ctx, err, PhaseConnect)
+		return err
+	}
+	qt := &quic.Transport{Conn: udpConn}
+	defer udpConn.Close()
+	defer qt.Close()
+
+	stats := &quicStats{}
+	var conn *quic.Conn
+	var connectStart time.Time
+	var handshake time.Duration
+	var handshakeDone chan struct{}
+	tlsCfg := p.tlsConfig()
+	tlsCfg.ServerName = host
+	tlsCfg.ClientSessionCache = p.sessionCache
+	transport := &http3.Transport{
+		TLSClientConfig: tlsCfg,
+		QUICConfig:      &quic.Config{Tracer: stats.tracer},
+		Dial: func(ctx context.Context, _ string, tlsCfg *tls.Config, cfg *quic.Config) (*quic.Conn, error) {
+			// the quic handshake is both the connect and the tls phase
+			phase.set(PhaseConnect)
+			ctx, cancel := withTimeout(ctx, p.Timeouts.Connect)
+			defer cancel()
+			connectStart = time.Now()
+			c, err := qt.DialEarly(ctx, addr, tlsCfg, cfg)
+			if err != nil {
+				return nil, err
+			}
+			conn = c
+			done := make(chan struct{})
+			handshakeDone = done
+			// do not wait for the handshake, the request may go out as 0-RTT data
+			go func() {
+				select {
+				case <-c.HandshakeComplete():
+					handshake = time.Since(connectStart)
+				case <-c.Context().Done():
+				}
+				close(done)
+			}()
+			return c, nil
+		},
+	}
+	defer transport.Close()
+
+	var wroteRequest, firstByte time.Time
+	trace := &httptrace.ClientTrace{
+		WroteRequest:         func(httptrace.WroteRequestInfo) { wroteRequest = time.Now() },
+		GotFirstResponseByte: func() { firstByte = time.Now() },
+	}
+	ctx, timer := p.phaseTrace(httptrace.WithClientTrace(ctx, trace), phase.set)
+	defer timer.close()
+	req := p.Req.WithContext(ctx)
+	client := &http.Client{Transport: transport, CheckRedirect: p.checkRedirect, Timeout: p.Timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		httpInfo.TLS = failedTLSInfo(err)
+		setError(httpInfo, ctx, err, phase.get())
+		return err
+	}
+	timer.start(p.Timeouts.Body)
+	defer resp.Body.Close()
+	if resp.TLS != nil {
+		httpInfo.TLS = newTLSInfo(resp.TLS, host, p.VerifyHost, p.RootCAs)
+	}
+	httpInfo.Code = resp.StatusCode
+	httpInfo.Proto = resp.Proto
+	httpInfo.header = resp.Header
+	httpInfo.uncompressed = resp.Uncompressed
+	httpInfo.TtfbMs = uint32(firstByte.Sub(wroteRequest).Milliseconds())
+
+	if resp.ContentLength > 0 {
+		err = readN(resp.Body, int(resp.ContentLength), p.bodyWriter(httpInfo))
+	} else {
+		err = readAll(resp.Body, p.bodyWriter(httpInfo))
+	}
+	if err != nil {
+		setError(httpInfo, ctx, err, PhaseBody)
+		return err
+	}
+	endTime := time.Now()
+	<-handshakeDone
+
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	httpInfo.H3 = &H3Info{
+		Version:         stats.version.String(),
+		HandshakeTimeMs: uint32(handshake.Milliseconds()),
+		Used0RTT:        conn.ConnectionState().Used0RTT,
+		SentPackets:     stats.sentPackets,
+		SentLostPackets: stats.lostPackets,
+	}
+	if stats.sentPackets != 0 {
+		httpInfo.H3.SentLossPct = float32(stats.lostPackets) / float32(stats.sentPackets) * 100.0
+	}
+	httpInfo.ConnectTimeMs = httpInfo.H3.HandshakeTimeMs
+	httpInfo.Client.RttMs = uint32(stats.rtt.Milliseconds())
+	httpInfo.Client.RttVarMs = uint32(stats.rttVar.Milliseconds())
+	httpInfo.TotalSize = stats.receivedBytes
+	httpInfo.TotalTimeMs = endTime.Sub(connectStart).Milliseconds()
+	httpInfo.Speed = speed(stats.receivedBytes, wroteRequest, endTime, httpInfo.Client.RttMs)
+	return nil
+}
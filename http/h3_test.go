@@ -0,0 +1,68 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHttp3(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, 256*1024))
+	})
+	// borrow the localhost certificate of httptest
+	ts := httptest.NewTLSServer(handler)
+	defer ts.Close()
+
+	udpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	assert.Nil(t, err)
+	server := &http3.Server{
+		Handler:    handler,
+		TLSConfig:  http3.ConfigureTLSConfig(&tls.Config{Certificates: ts.TLS.Certificates}),
+		QUICConfig: &quic.Config{Allow0RTT: true},
+	}
+	go server.Serve(udpConn)
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, "https://"+udpConn.LocalAddr().String()+"/", nil)
+	assert.Nil(t, err)
+	p := Pinger{Req: req, Proto: ProtoHTTP3, Count: 2}
+	r, err := p.PingCount(context.Background(), nil)
+	assert.Nil(t, err)
+	assert.Len(t, r.Infos, 2)
+	for _, info := range r.Infos {
+		assert.Equal(t, "", info.Error)
+		assert.Equal(t, 200, info.Code)
+		assert.Equal(t, "HTTP/3.0", info.Proto)
+		assert.True(t, info.TotalSize > 256*1024)
+		assert.NotZero(t, info.H3.SentPackets)
+		// the client loss is not the download loss of Info.Loss
+		assert.Zero(t, info.Loss)
+		assert.Zero(t, info.ReTransmitPackets)
+	}
+	// every ping does a full handshake like over tcp
+	assert.False(t, r.Infos[0].H3.Used0RTT)
+	assert.False(t, r.Infos[1].H3.Used0RTT)
+	assert.False(t, r.Infos[1].TLS.Resumed)
+
+	resume, err := p.PingResume(context.Background())
+	assert.Nil(t, err)
+	assert.True(t, resume.Resumption)
+	assert.True(t, resume.EarlyData)
+}
+
+func TestHttp3Redirect(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://127.0.0.1:1/", nil)
+	assert.Nil(t, err)
+	p := Pinger{Req: req, Proto: ProtoHTTP3, Redirect: true}
+	info, err := p.Ping()
+	assert.ErrorIs(t, err, ErrH3Redirect)
+	assert.Equal(t, PhaseConnect, info.ErrorPhase)
+}
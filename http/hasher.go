@@ -0,0 +1,257 @@
+package http
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"hash/crc32"
+	"hash/crc64"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+const (
+	HashMD5    = "md5"
+	HashSHA1   = "sha1"
+	HashSHA256 = "sha256"
+	HashCRC32  = "crc32"
+	HashCRC64  = "crc64" // ecma, like the crc64ecma headers of aliyun oss and tencent cos
+	HashXXHash = "xxhash"
+	HashQETag  = "qetag" // the etag of qiniu kodo, sha1 of the sha1 of every 4MB block
+)
+
+type Hasher struct {
+	Name   string
+	New    func() hash.Hash
+	Encode func(sum []byte) string // how the sum is shown in Info.Hash, nil is hex
+}
+
+var (
+	hasherMu sync.RWMutex
+	hashers  = map[string]Hasher{}
+)
+
+var crc64Table = crc64.MakeTable(crc64.ECMA)
+
+func init() {
+	RegisterHasher(Hasher{Name: HashMD5, New: md5.New})
+	RegisterHasher(Hasher{Name: HashSHA1, New: sha1.New})
+	RegisterHasher(Hasher{Name: HashSHA256, New: sha256.New})
+	RegisterHasher(Hasher{Name: HashCRC32, New: func() hash.Hash { return crc32.NewIEEE() }})
+	RegisterHasher(Hasher{Name: HashCRC64, New: func() hash.Hash { return crc64.New(crc64Table) }})
+	RegisterHasher(Hasher{Name: HashXXHash, New: func() hash.Hash { return xxhash.New() }})
+	RegisterHasher(Hasher{Name: HashQETag, New: newQETag, Encode: base64.URLEncoding.EncodeToString})
+}
+
+// RegisterHasher adds or replaces a hasher, the name is case insensitive.
+func RegisterHasher(h Hasher) {
+	hasherMu.Lock()
+	defer hasherMu.Unlock()
+	hashers[strings.ToLower(h.Name)] = h
+}
+
+func GetHasher(name string) (Hasher, bool) {
+	hasherMu.RLock()
+	defer hasherMu.RUnlock()
+	h, ok := hashers[strings.ToLower(name)]
+	return h, ok
+}
+
+// Hashers returns the registered names, sorted.
+func Hashers() []string {
+	hasherMu.RLock()
+	defer hasherMu.RUnlock()
+	var names []string
+	for name := range hashers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// encodeHash shows the sum like the registered hasher of the Pinger does.
+func (p *Pinger) encodeHash(sum []byte) string {
+	if h, ok := GetHasher(p.HashAlgo); ok && h.Encode != nil {
+		return h.Encode(sum)
+	}
+	return hex.EncodeToString(sum)
+}
+
+// sameHash ignores the case of a hex sum only, the case of base64 matters.
+func (p *Pinger) sameHash(a, b string) bool {
+	if h, ok := GetHasher(p.HashAlgo); ok && h.Encode != nil {
+		return a == b
+	}
+	return strings.EqualFold(a, b)
+}
+
+const qetagBlock = 4 << 20
+
+// qetag hashes every 4MB block with sha1, one block gives 0x16 and its sha1,
+// more blocks give 0x96 and the sha1 of all the block sha1.
+type qetag struct {
+	block  hash.Hash
+	n      int
+	blocks []byte
+}
+
+func newQETag() hash.Hash {
+	return &qetag{block: sha1.New()}
+}
+
+func (q *qetag) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		n := minInt(qetagBlock-q.n, len(p))
+		q.block.Write(p[:n])
+		q.n += n
+		p = p[n:]
+		if q.n == qetagBlock {
+			q.blocks = q.block.Sum(q.blocks)
+			q.block.Reset()
+			q.n = 0
+		}
+	}
+	return written, nil
+}
+
+func (q *qetag) Sum(b []byte) []byte {
+	blocks := append([]byte(nil), q.blocks...)
+	if q.n > 0 || len(blocks) == 0 {
+		blocks = q.block.Sum(blocks)
+	}
+	if len(blocks) == sha1.Size {
+		return append(append(b, 0x16), blocks...)
+	}
+	s := sha1.Sum(blocks)
+	return append(append(b, 0x96), s[:]...)
+}
+
+func (q *qetag) Reset() {
+	q.block.Reset()
+	q.n = 0
+	q.blocks = nil
+}
+
+func (q *qetag) Size() int { return sha1.Size + 1 }
+
+func (q *qetag) BlockSize() int { return q.block.BlockSize() }
+
+// HashCheck is the body hash compared with a hash the response carries.
+type HashCheck struct {
+	Header   string
+	Expected string
+	Match    bool
+}
+
+// hashHeader reads the sum of algo from the value of a header, ok is false
+// when the value holds no sum of algo.
+type hashHeader struct {
+	header string
+	parse  func(value, algo string) (sum []byte, ok bool)
+}
+
+var hashHeaders = []hashHeader{
+	{"Content-MD5", func(v, algo string) ([]byte, bool) {
+		return decodeSum(v, algo == HashMD5, md5.Size, base64.StdEncoding)
+	}},
+	{"ETag", parseETag},
+	{"X-Goog-Hash", func(v, algo string) ([]byte, bool) {
+		return digestSum(v, algo, map[string]string{"md5": HashMD5})
+	}},
+	{"Digest", func(v, algo string) ([]byte, bool) {
+		return digestSum(v, algo, map[string]string{"md5": HashMD5, "sha": HashSHA1, "sha-256": HashSHA256})
+	}},
+	{"Content-Digest", func(v, algo string) ([]byte, bool) {
+		return digestSum(v, algo, map[string]string{"md5": HashMD5, "sha": HashSHA1, "sha-256": HashSHA256})
+	}},
+	{"X-Amz-Checksum-Sha256", func(v, algo string) ([]byte, bool) {
+		return decodeSum(v, algo == HashSHA256, sha256.Size, base64.StdEncoding)
+	}},
+	{"X-Amz-Checksum-Sha1", func(v, algo string) ([]byte, bool) {
+		return decodeSum(v, algo == HashSHA1, sha1.Size, base64.StdEncoding)
+	}},
+	{"X-Amz-Checksum-Crc32", func(v, algo string) ([]byte, bool) {
+		return decodeSum(v, algo == HashCRC32, crc32.Size, base64.StdEncoding)
+	}},
+	{"X-Oss-Hash-Crc64ecma", parseCRC64},
+	{"X-Cos-Hash-Crc64ecma", parseCRC64},
+}
+
+func decodeSum(v string, want bool, size int, enc *base64.Encoding) ([]byte, bool) {
+	if !want {
+		return nil, false
+	}
+	sum, err := enc.DecodeString(strings.TrimSpace(v))
+	if err != nil || len(sum) != size {
+		return nil, false
+	}
+	return sum, true
+}
+
+// parseETag takes a strong etag that has the size of the sum, the hex md5
+// of S3 like servers or the qetag of qiniu, other etags are not hashes.
+func parseETag(v, algo string) ([]byte, bool) {
+	if strings.HasPrefix(v, "W/") {
+		return nil, false
+	}
+	v = strings.Trim(v, `"`)
+	switch algo {
+	case HashMD5:
+		sum, err := hex.DecodeString(v)
+		return sum, err == nil && len(sum) == md5.Size
+	case HashQETag:
+		return decodeSum(v, true, sha1.Size+1, base64.URLEncoding)
+	}
+	return nil, false
+}
+
+// digestSum reads entries like "md5=base64" or "sha-256=:base64:".
+func digestSum(v, algo string, names map[string]string) ([]byte, bool) {
+	for _, entry := range strings.Split(v, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || names[strings.ToLower(name)] != algo {
+			continue
+		}
+		h, _ := GetHasher(algo)
+		return decodeSum(strings.Trim(value, ":"), true, h.New().Size(), base64.StdEncoding)
+	}
+	return nil, false
+}
+
+func parseCRC64(v, algo string) ([]byte, bool) {
+	if algo != HashCRC64 {
+		return nil, false
+	}
+	n, err := strconv.ParseUint(strings.TrimSpace(v), 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	return binary.BigEndian.AppendUint64(nil, n), true
+}
+
+// verifyHash compares sum with every hash of algo the response headers carry.
+func verifyHash(algo string, sum []byte, header http.Header) []HashCheck {
+	var checks []HashCheck
+	algo = strings.ToLower(algo)
+	for _, h := range hashHeaders {
+		v := header.Get(h.header)
+		if v == "" {
+			continue
+		}
+		if expected, ok := h.parse(v, algo); ok {
+			checks = append(checks, HashCheck{Header: h.header, Expected: v, Match: bytes.Equal(expected, sum)})
+		}
+	}
+	return checks
+}
@@ -0,0 +1,125 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"hash/crc64"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQETag(t *testing.T) {
+	q := newQETag()
+	assert.Equal(t, "Fto5o-5ea0sNMlW_75VgGJCv2AcJ", base64.URLEncoding.EncodeToString(q.Sum(nil)))
+
+	data := bytes.Repeat([]byte("0123456789abcdef"), (qetagBlock+100)/16)
+	q.Write(data[:1000])
+	q.Write(data[1000:])
+	b1 := sha1.Sum(data[:qetagBlock])
+	b2 := sha1.Sum(data[qetagBlock:])
+	all := sha1.Sum(append(b1[:], b2[:]...))
+	assert.Equal(t, append([]byte{0x96}, all[:]...), q.Sum(nil))
+
+	q.Reset()
+	q.Write(data[:qetagBlock])
+	assert.Equal(t, append([]byte{0x16}, b1[:]...), q.Sum(nil))
+}
+
+func TestVerifyHash(t *testing.T) {
+	body := []byte("hello")
+	md5Sum := md5.Sum(body)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-MD5", base64.StdEncoding.EncodeToString(md5Sum[:]))
+		if r.URL.Path == "/stale" {
+			w.Header().Set("ETag", `"00000000000000000000000000000000"`)
+		} else {
+			w.Header().Set("ETag", `"`+hex.EncodeToString(md5Sum[:])+`"`)
+		}
+		w.Header().Set("X-Oss-Hash-Crc64ecma", strconv.FormatUint(crc64.Checksum(body, crc64Table), 10))
+		switch r.URL.Path {
+		case "/notfound":
+			w.WriteHeader(http.StatusNotFound)
+		case "/gzip":
+			// the headers are of the encoded body, the transport decodes it
+			var b bytes.Buffer
+			z := gzip.NewWriter(&b)
+			z.Write(body)
+			z.Close()
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(b.Bytes())
+			return
+		}
+		w.Write(body)
+	}))
+	defer ts.Close()
+
+	ping := func(path, algo string) *Info {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		assert.Nil(t, err)
+		h, ok := GetHasher(algo)
+		assert.True(t, ok)
+		p := Pinger{Req: req, BodyHasher: h.New(), HashAlgo: algo, Assertions: &Assertions{}}
+		info, err := p.Ping()
+		assert.Nil(t, err)
+		return info
+	}
+
+	info := ping("/", HashMD5)
+	assert.Equal(t, []HashCheck{
+		{Header: "Content-MD5", Expected: base64.StdEncoding.EncodeToString(md5Sum[:]), Match: true},
+		{Header: "ETag", Expected: `"` + hex.EncodeToString(md5Sum[:]) + `"`, Match: true},
+	}, info.HashChecks)
+	assert.Equal(t, VerdictPass, info.Verdict)
+
+	info = ping("/", HashCRC64)
+	if assert.Len(t, info.HashChecks, 1) {
+		assert.True(t, info.HashChecks[0].Match)
+	}
+
+	info = ping("/stale", HashMD5)
+	if assert.Len(t, info.HashChecks, 2) {
+		assert.False(t, info.HashChecks[1].Match)
+	}
+	assert.Equal(t, VerdictFail, info.Verdict)
+
+	info = ping("/", HashXXHash)
+	assert.Empty(t, info.HashChecks)
+	assert.Len(t, info.Hash, 16)
+
+	info = ping("/notfound", HashMD5)
+	assert.Empty(t, info.HashChecks)
+	info = ping("/gzip", HashMD5)
+	assert.Empty(t, info.HashChecks)
+	assert.Equal(t, VerdictPass, info.Verdict)
+}
+
+func TestExpectedHashCase(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer ts.Close()
+
+	ping := func(algo, expected string) error {
+		req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+		assert.Nil(t, err)
+		h, _ := GetHasher(algo)
+		p := Pinger{Req: req, BodyHasher: h.New(), HashAlgo: algo, ExpectedHash: expected}
+		_, err = p.Ping()
+		return err
+	}
+	assert.Nil(t, ping(HashMD5, "5D41402ABC4B2A76B9719D911017C592"))
+	q := newQETag()
+	q.Write([]byte("hello"))
+	etag := base64.URLEncoding.EncodeToString(q.Sum(nil))
+	assert.Nil(t, ping(HashQETag, etag))
+	assert.NotNil(t, ping(HashQETag, strings.ToLower(etag)))
+}
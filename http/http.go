@@ -1,8 +1,11 @@
 package http
 
 import (
-	"encoding/hex"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"hash"
 	"io"
 	"net/http"
@@ -11,22 +14,49 @@ import (
 	"unsafe"
 
 	"github.com/qiniu/httpping/command"
+	"github.com/qiniu/httpping/dns"
 	"github.com/qiniu/httpping/network"
 )
 
 type Pinger struct {
-	Req           *http.Request
-	SysPing       bool
-	SrcAddr       string
-	ServerSupport bool
-	BodyHasher    hash.Hash
-	Redirect      bool
-	Timeout       time.Duration
-	ServerIp      string
-	VerifyHost    bool
+	Req                *http.Request
+	SysPing            bool
+	SrcAddr            string
+	ServerSupport      bool
+	BodyHasher         hash.Hash
+	NewHasher          func() hash.Hash // used instead of BodyHasher by the concurrent modes
+	Redirect           bool
+	Timeout            time.Duration
+	ServerIp           string            // only for the first hop of a redirect chain
+	HostIps            map[string]string // host to ip for every hop of a redirect chain
+	VerifyHost         bool
+	Count              int
+	Interval           time.Duration
+	Proto              string
+	Network            string // tcp4 or tcp6 to probe only one address family, default tcp
+	Resolver           dns.Resolver
+	ClientCerts        []tls.Certificate
+	RootCAs            *x509.CertPool // nil uses the system roots
+	Pins               []string       // base64 sha256 of a SPKI in the chain, checked even without VerifyHost
+	Proxy              *url.URL       // http CONNECT or socks5 tunnel, see ParseProxy
+	Timeouts           Timeouts
+	HashAlgo           string        // registered name of BodyHasher, checks the body against ETag, Content-MD5 and the vendor hash headers
+	ExpectedHash       string        // BodyHasher sum like Info.Hash, a different body fails with hash_mismatch
+	ThroughputInterval time.Duration // interval of Info.Throughput, default 100ms
+	Assertions         *Assertions
+	TCPSampleInterval  time.Duration          // sample TCP_INFO into Info.TCPSamples while the body downloads, 0 disables
+	CDN                bool                   // classify the cache status into Info.CDN, see RegisterCDN
+	sessionCache       tls.ClientSessionCache // only PingResume keeps the tls sessions
+	body               io.Writer              // the full body, for PingRanges
 }
 
+// RoundTime is one request of the redirect chain, NewConn without DnsTimeMs,
+// ConnectTimeMs and TLS when the previous connection was reused.
 type RoundTime struct {
+	Url                string
+	Code               int
+	Location           string
+	NewConn            bool
 	Domain             string
 	Ip                 string
 	Port               int
@@ -36,6 +66,7 @@ type RoundTime struct {
 	TtfbMs             uint32
 	TotalSize          int64
 	TotalTimeMs        int64
+	TLS                *TLSInfo `json:",omitempty"`
 }
 
 type Info struct {
@@ -45,6 +76,7 @@ type Info struct {
 	Ip                 string
 	Port               int
 	Code               int
+	Proto              string
 	Hops               uint32
 	DnsTimeMs          uint32
 	ConnectTimeMs      uint32
@@ -55,10 +87,31 @@ type Info struct {
 	TotalSize          int64
 	TotalTimeMs        int64
 	Error              string
+	ErrorCode          string `json:",omitempty"` // also set for 4xx and 5xx responses, which are not errors of Ping
+	ErrorPhase         string `json:",omitempty"`
 	PingError          string
 	Hash               string
+	HashChecks         []HashCheck `json:",omitempty"`
 	Loss               float32
-	Rounds             []RoundTime
+	Rounds             []RoundTime       // the redirects before the final response
+	Url                string            `json:",omitempty"` // the final url after redirects
+	H2                 *H2Info           `json:",omitempty"`
+	H3                 *H3Info           `json:",omitempty"`
+	Dns                *dns.Result       `json:",omitempty"`
+	Upload             *UploadInfo       `json:",omitempty"`
+	TLS                *TLSInfo          `json:",omitempty"`
+	Proxy              *ProxyInfo        `json:",omitempty"`
+	TimeoutPhase       string            `json:",omitempty"`
+	Throughput         *Throughput       `json:",omitempty"`
+	TCPSamples         []TCPSample       `json:",omitempty"`
+	TCPStats           *network.TCPStats `json:",omitempty"`
+	ClientLoss         *LossEstimate     `json:",omitempty"` // Loss needs the server, this does not
+	Verdict            string            `json:",omitempty"` // pass or fail when the Pinger has Assertions
+	FailedAssertions   []string          `json:",omitempty"`
+	CDN                *CDNInfo          `json:",omitempty"`
+	header             http.Header
+	uncompressed       bool // the transport decoded the body, the hash headers are of the encoded one
+	body               *limitedBuffer
 }
 
 func (h *Info) String() string {
@@ -74,7 +127,7 @@ func minInt(x, y int) int {
 	}
 }
 
-func readN(b io.ReadCloser, toRead int, hasher hash.Hash) (err error) {
+func readN(b io.ReadCloser, toRead int, hasher io.Writer) (err error) {
 	d := make([]byte, 64*1024)
 	var n int
 	for {
@@ -112,7 +165,7 @@ func dealWithServerTcpInfo(b io.ReadCloser, contentLength int64, tcpInfo *networ
 	return
 }
 
-func readAll(b io.ReadCloser, hasher hash.Hash) (err error) {
+func readAll(b io.ReadCloser, hasher io.Writer) (err error) {
 	d := make([]byte, 64*1024)
 	var n int
 	for {
@@ -135,10 +188,9 @@ func hops(ttl uint) uint32 {
 		return uint32(64 - ttl)
 	} else if ttl <= 128 {
 		return uint32(128 - ttl)
-	} else if ttl <= 256 {
-		return uint32(256 - ttl)
 	} else {
-		return uint32(512 - ttl)
+		// ipv4 ttl and ipv6 hop limit are both 8 bits, max 255
+		return uint32(255 - minInt(int(ttl), 255))
 	}
 }
 
@@ -168,20 +220,51 @@ func sysPing(httpInfo *Info, addr, srcAddr string, wait chan<- int) {
 	wait <- 1
 }
 
-func (p *Pinger) Ping() (*Info, error) {
-	pWait := make(chan int, 1)
-	var httpInfo Info
+func (p *Pinger) normalizeURL() error {
 	u := p.Req.URL
-	var err error
 	if u.Scheme == "" {
+		var err error
 		u, err = url.Parse("http://" + u.String())
 		if err != nil {
-			return nil, err
+			return err
 		}
 		p.Req.URL = u
 	}
+	return nil
+}
 
-	w := &TcpWrapper{localAddr: p.SrcAddr, ip: p.ServerIp, verifyHost: p.VerifyHost}
+func (p *Pinger) newWrapper() *TcpWrapper {
+	return &TcpWrapper{localAddr: p.SrcAddr, ip: p.ServerIp, network: p.Network, resolver: p.Resolver, verifyHost: p.VerifyHost,
+		tlsConfig: p.tlsConfig(), sessionCache: p.sessionCache, proxy: p.Proxy, timeouts: p.Timeouts,
+		series: byteSeries{interval: p.ThroughputInterval}, hostIps: p.HostIps}
+}
+
+// Ping runs with the context of the request.
+func (p *Pinger) Ping() (*Info, error) {
+	return p.PingContext(p.Req.Context())
+}
+
+// PingContext returns the Info also when the probe failed, the error is then
+// a *ProbeError. A nil Info means the request could not be sent at all.
+func (p *Pinger) PingContext(ctx context.Context) (*Info, error) {
+	pWait := make(chan int, 1)
+	var httpInfo Info
+	err := p.normalizeURL()
+	if err != nil {
+		return nil, err
+	}
+	if p.ExpectedHash != "" && p.BodyHasher == nil {
+		return nil, errors.New("ExpectedHash without a BodyHasher, no hasher to check it")
+	}
+	if p.Req.GetBody != nil {
+		// every attempt and every concurrent clone needs its own body
+		p.Req.Body, err = p.Req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	w := p.newWrapper()
 
 	if p.SysPing {
 		w.ping = func(addr string) {
@@ -189,95 +272,183 @@ func (p *Pinger) Ping() (*Info, error) {
 		}
 	}
 
-	err = p.do(&httpInfo, w)
+	if p.Proto == ProtoHTTP3 {
+		err = p.doH3(ctx, &httpInfo, w.ping)
+	} else {
+		err = p.do(ctx, &httpInfo, w)
+	}
+	if p.CDN {
+		httpInfo.CDN = ParseCDN(httpInfo.header)
+	}
 	if err != nil {
-		return &httpInfo, nil
+		if p.Assertions != nil {
+			p.check(&httpInfo)
+		}
+		return &httpInfo, &ProbeError{Code: httpInfo.ErrorCode, Phase: httpInfo.ErrorPhase, Err: err}
 	}
 
-	endTime := time.Now()
-	httpInfo.TotalSize = w.count
-	httpInfo.TotalTimeMs = endTime.Sub(w.connectStart).Milliseconds()
-	//use last write to calculate download speed to avoid small request that firstRead == endTime
-	t := endTime.Sub(w.lastWrite).Milliseconds() - int64(httpInfo.Client.RttMs)
-	if t <= 0 {
-		t = 1
+	if p.Proto != ProtoHTTP3 {
+		endTime := time.Now()
+		received := w.received()
+		httpInfo.TotalSize = received
+		httpInfo.TotalTimeMs = endTime.Sub(w.connectStart).Milliseconds()
+		//use last write to calculate download speed to avoid small request that firstRead == endTime
+		httpInfo.Speed = speed(received, w.requestEnd(), endTime, httpInfo.Client.RttMs)
+		httpInfo.Throughput = w.throughput()
+		httpInfo.ClientLoss = estimateLoss(httpInfo.TCPStats, endTime.Sub(w.requestEnd()).Milliseconds())
+		if httpInfo.ClientLoss != nil && httpInfo.Server.TotalPackets != 0 {
+			serverLoss := httpInfo.Loss
+			httpInfo.ClientLoss.ServerLossPct = &serverLoss
+		}
 	}
-	httpInfo.Speed = float32(float64(w.count) / float64(t))
 	if p.SysPing {
 		<-pWait
 	}
+	if httpInfo.Code >= 400 {
+		httpInfo.ErrorCode = StatusErrorCode(httpInfo.Code)
+		httpInfo.ErrorPhase = PhaseHTTP
+	}
 	if p.BodyHasher != nil {
-		httpInfo.Hash = hex.EncodeToString(p.BodyHasher.Sum(nil))
+		sum := p.BodyHasher.Sum(nil)
+		httpInfo.Hash = p.encodeHash(sum)
+		// the hash headers are of the full object, not of an error page or a part
+		if p.HashAlgo != "" && httpInfo.Code == http.StatusOK && !httpInfo.uncompressed {
+			httpInfo.HashChecks = verifyHash(p.HashAlgo, sum, httpInfo.header)
+		}
+		if p.ExpectedHash != "" && !p.sameHash(p.ExpectedHash, httpInfo.Hash) {
+			err = &HashMismatchError{Expected: p.ExpectedHash, Got: httpInfo.Hash}
+			setError(&httpInfo, ctx, err, PhaseBody)
+		}
+	}
+	if p.Assertions != nil {
+		p.check(&httpInfo)
+	}
+	if err != nil {
+		return &httpInfo, &ProbeError{Code: httpInfo.ErrorCode, Phase: httpInfo.ErrorPhase, Err: err}
 	}
-
 	return &httpInfo, nil
 }
 
-func (p *Pinger) do(httpInfo *Info, w *TcpWrapper) error {
+func speed(count int64, start, end time.Time, rttMs uint32) float32 {
+	t := end.Sub(start).Milliseconds() - int64(rttMs)
+	if t <= 0 {
+		t = 1
+	}
+	return float32(float64(count) / float64(t))
+}
+
+func (p *Pinger) checkRedirect(req *http.Request, via []*http.Request) error {
+	if p.Redirect {
+		return nil
+	}
+	return http.ErrUseLastResponse
+}
+
+func (p *Pinger) do(ctx context.Context, httpInfo *Info, w *TcpWrapper) error {
+	var transport http.RoundTripper = &http.Transport{DialContext: w.Dial, DialTLSContext: w.DialTLS}
+	var h2t *h2Transport
+	if p.Proto == ProtoHTTP2 {
+		h2t = newH2Transport(w)
+		transport = h2t
+	}
+	var hops *hopRecorder
+	if p.Redirect {
+		hops = &hopRecorder{next: transport, w: w, p: p}
+		transport = hops
+	}
 	client := &http.Client{
-		Transport: &http.Transport{DialContext: w.Dial, DialTLSContext: w.DialTLS},
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			if p.Redirect {
-				return nil
-			}
-			return http.ErrUseLastResponse
-		}, Timeout: p.Timeout,
+		Transport:     transport,
+		CheckRedirect: p.checkRedirect,
+		Timeout:       p.Timeout,
 	}
 	if p.ServerSupport {
 		p.Req.Header.Set("X-HTTPPING-REQUIRE", "TCPINFO")
 	}
 
-	resp, err := client.Do(p.Req)
+	ctx, timer := p.phaseTrace(ctx, w.phase.set)
+	defer timer.close()
+	resp, err := client.Do(p.Req.WithContext(ctx))
+	if h2t != nil {
+		httpInfo.H2 = h2t.h2Info()
+	}
 	httpInfo.Domain = w.domain
 	if w.remoteAddr != nil {
 		httpInfo.Ip = w.remoteAddr.IP.String()
 		httpInfo.Port = w.remoteAddr.Port
 		httpInfo.DnsTimeMs = uint32(w.dnsTime.Milliseconds())
+		httpInfo.Dns = w.dnsResult
+	}
+
+	if w.proxy != nil && w.remoteAddr != nil {
+		httpInfo.Proxy = w.proxyInfo()
+	}
+
+	if w.tlsState != nil {
+		httpInfo.TLS = newTLSInfo(w.tlsState, w.domain, w.verifyHost, p.RootCAs)
+	} else if w.tlsErr != nil {
+		httpInfo.TLS = failedTLSInfo(w.tlsErr)
 	}
 
 	if err != nil {
-		httpInfo.Error = err.Error()
+		setError(httpInfo, ctx, err, w.Phase())
 		return err
 	}
+	timer.start(p.Timeouts.Body)
 	httpInfo.ConnectTimeMs = uint32(w.tcpHandshake.Milliseconds())
 	httpInfo.TLSHandshakeTimeMs = uint32(w.tlsHandshake.Milliseconds())
 	httpInfo.TtfbMs = uint32(w.TTFB().Milliseconds())
+	if hasBody(p.Req) {
+		httpInfo.Upload = uploadInfo(p.Req, w)
+	}
 
 	defer w.Close()
 	defer resp.Body.Close()
 	httpInfo.Code = resp.StatusCode
+	httpInfo.Proto = resp.Proto
+	httpInfo.header = resp.Header
+	httpInfo.uncompressed = resp.Uncompressed
 	var done string
 	if p.ServerSupport {
 		done = resp.Header.Get("X-HTTPPING-TCPINFO")
 	}
+	var sampler *tcpSampler
+	if d := w.conn(); p.TCPSampleInterval > 0 && d != nil {
+		sampler = sampleTCP(d, p.TCPSampleInterval)
+	}
 	if done != "" && resp.ContentLength > 0 {
 		err = dealWithServerTcpInfo(resp.Body, resp.ContentLength, &httpInfo.Server)
 	} else if resp.ContentLength > 0 {
-		err = readN(resp.Body, int(resp.ContentLength), p.BodyHasher)
+		err = readN(resp.Body, int(resp.ContentLength), p.bodyWriter(httpInfo))
 	} else {
-		err = readAll(resp.Body, p.BodyHasher)
+		err = readAll(resp.Body, p.bodyWriter(httpInfo))
+	}
+	if sampler != nil {
+		httpInfo.TCPSamples = sampler.stop()
 	}
 	if err == io.EOF {
 		err = nil
 	}
 	if err != nil {
-		httpInfo.Error = err.Error()
+		setError(httpInfo, ctx, err, PhaseBody)
 		return err
 	}
-	if w.rounds != nil {
-		httpInfo.Rounds = w.rounds
+	if hops != nil {
+		hops.finish()
+		if n := len(hops.hops); n > 1 {
+			httpInfo.Rounds = hops.hops[:n-1]
+			httpInfo.Url = hops.hops[n-1].Url
+		}
 	}
 
-	tcpInfo, err := w.CommonInfo()
-	if err != nil {
-		httpInfo.Error = err.Error()
-	} else {
+	// the tcp info is best effort, the response is complete already
+	if tcpInfo, err := w.CommonInfo(); err == nil {
 		httpInfo.Client = *tcpInfo
 	}
+	httpInfo.TCPStats, _ = w.TCPStats()
 
 	if done != "" && resp.ContentLength != 0 {
 		if httpInfo.Server.TotalPackets == 0 {
-			httpInfo.Server.TotalPackets = uint32(w.count / 1460)
+			httpInfo.Server.TotalPackets = uint32(w.received() / 1460)
 			if httpInfo.Server.TotalPackets == 0 {
 				httpInfo.Server.TotalPackets = 1
 			}
@@ -287,7 +458,7 @@ func (p *Pinger) do(httpInfo *Info, w *TcpWrapper) error {
 			httpInfo.ReTransmitPackets = httpInfo.Server.ReTransmitPackets
 		}
 	}
-	return err
+	return nil
 }
 
 func Ping(req *http.Request, ping bool, srcAddr string) (*Info, error) {
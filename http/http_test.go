@@ -2,11 +2,15 @@ package http
 
 import (
 	"fmt"
+	"net"
 	"testing"
 )
 import "github.com/stretchr/testify/assert"
 
 func TestHttp(t *testing.T) {
+	if _, err := net.LookupHost("www.baidu.com"); err != nil {
+		t.Skip("no network:", err)
+	}
 	h, err := PingSimple("www.baidu.com")
 	fmt.Println(h, err)
 	assert.Nil(t, err)
@@ -0,0 +1,134 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+type KeepAliveRequest struct {
+	Seq         int
+	NewConn     bool
+	ServerClose bool // the response asked to close the connection
+	Code        int
+	Proto       string
+	TtfbMs      uint32
+	TotalTimeMs int64
+	TotalSize   int64
+	Speed       float32 // unit kb/s
+	Error       string
+}
+
+type KeepAliveResult struct {
+	Requests         []KeepAliveRequest
+	Connections      int
+	KeptAlive        bool // all the requests went over one connection
+	UnexpectedCloses int  // reconnections the server did not announce
+	ColdTtfbMs       uint32
+	WarmTtfbMs       float32
+	ColdSpeed        float32
+	WarmSpeed        float32
+}
+
+func (r *KeepAliveResult) String() string {
+	t, _ := json.MarshalIndent(r, "", "	")
+	return string(t)
+}
+
+// PingKeepAlive sends n sequential requests over one persistent connection,
+// a new connection is only opened when the server closed the previous one.
+// With ProtoHTTP2 the requests are streams of one h2 connection, h3 is not
+// supported.
+func (p *Pinger) PingKeepAlive(ctx context.Context, n int) (*KeepAliveResult, error) {
+	if p.Proto == ProtoHTTP3 {
+		return nil, errors.New("keep-alive over h3 is not supported")
+	}
+	err := p.normalizeURL()
+	if err != nil {
+		return nil, err
+	}
+	w := p.newWrapper()
+	defer w.Close()
+	var transport http.RoundTripper = &http.Transport{
+		DialContext:         w.Dial,
+		DialTLSContext:      w.DialTLS,
+		MaxConnsPerHost:     1,
+		MaxIdleConnsPerHost: 1,
+	}
+	if p.Proto == ProtoHTTP2 {
+		transport = newH2Transport(w)
+	}
+	client := &http.Client{
+		Transport:     transport,
+		CheckRedirect: p.checkRedirect,
+		Timeout:       p.Timeout,
+	}
+
+	r := &KeepAliveResult{}
+	var warmTtfb, warmSpeed float32
+	var warm int
+	serverClose := false
+	for seq := 0; seq < n && ctx.Err() == nil; seq++ {
+		req := p.Req.Clone(ctx)
+		if p.Req.GetBody != nil {
+			req.Body, err = p.Req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		kr := KeepAliveRequest{Seq: seq}
+		dials := w.dialCount()
+		countBefore := w.received()
+		w.resetTtfb()
+		start := time.Now()
+		resp, err := client.Do(req)
+		if err != nil {
+			kr.Error = err.Error()
+			r.Requests = append(r.Requests, kr)
+			continue
+		}
+		kr.Code = resp.StatusCode
+		kr.Proto = resp.Proto
+		kr.ServerClose = resp.Close
+		kr.TtfbMs = uint32(w.TTFB().Milliseconds())
+		err = readAll(resp.Body, nil)
+		resp.Body.Close()
+		end := time.Now()
+		if err != nil {
+			kr.Error = err.Error()
+		}
+
+		kr.NewConn = w.dialCount() != dials
+		if kr.NewConn && seq != 0 && !serverClose {
+			r.UnexpectedCloses++
+		}
+		serverClose = kr.ServerClose
+		kr.TotalTimeMs = end.Sub(start).Milliseconds()
+		kr.TotalSize = w.received() - countBefore
+		var rttMs uint32
+		if tcpInfo, err := w.CommonInfo(); err == nil {
+			rttMs = tcpInfo.RttMs
+		}
+		kr.Speed = speed(kr.TotalSize, w.requestEnd(), end, rttMs)
+		r.Requests = append(r.Requests, kr)
+
+		if seq == 0 {
+			r.ColdTtfbMs = kr.TtfbMs
+			r.ColdSpeed = kr.Speed
+		} else if !kr.NewConn && kr.Error == "" {
+			warm++
+			warmTtfb += float32(kr.TtfbMs)
+			warmSpeed += kr.Speed
+		}
+	}
+	r.Connections = w.dialCount()
+	r.KeptAlive = r.Connections == 1
+	if warm != 0 {
+		r.WarmTtfbMs = warmTtfb / float32(warm)
+		r.WarmSpeed = warmSpeed / float32(warm)
+	}
+	return r, nil
+}
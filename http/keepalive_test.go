@@ -0,0 +1,70 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeepAlive(t *testing.T) {
+	var closeConn atomic.Bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if closeConn.Load() {
+			w.Header().Set("Connection", "close")
+		}
+		w.Write(make([]byte, 16*1024))
+	}))
+	defer ts.Close()
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+	assert.Nil(t, err)
+	p := Pinger{Req: req}
+	r, err := p.PingKeepAlive(context.Background(), 3)
+	assert.Nil(t, err)
+	assert.Len(t, r.Requests, 3)
+	assert.Equal(t, 1, r.Connections)
+	assert.True(t, r.KeptAlive)
+	assert.True(t, r.Requests[0].NewConn)
+	assert.False(t, r.Requests[2].NewConn)
+
+	closeConn.Store(true)
+	r, err = p.PingKeepAlive(context.Background(), 3)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, r.Connections)
+	assert.False(t, r.KeptAlive)
+	assert.True(t, r.Requests[1].ServerClose)
+	assert.Equal(t, 0, r.UnexpectedCloses)
+}
+
+func TestKeepAliveProto(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, 16*1024))
+	}))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+	assert.Nil(t, err)
+	p := Pinger{Req: req, Proto: ProtoHTTP2}
+	r, err := p.PingKeepAlive(context.Background(), 3)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, r.Connections)
+	for _, kr := range r.Requests {
+		assert.Empty(t, kr.Error)
+		assert.Equal(t, "HTTP/2.0", kr.Proto)
+	}
+
+	p.Proto = ""
+	r, err = p.PingKeepAlive(context.Background(), 2)
+	assert.Nil(t, err)
+	assert.Equal(t, "HTTP/1.1", r.Requests[1].Proto)
+
+	p.Proto = ProtoHTTP3
+	_, err = p.PingKeepAlive(context.Background(), 2)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,79 @@
+package http
+
+import (
+	"github.com/qiniu/httpping/network"
+)
+
+const (
+	ConfidenceLow    = "low"
+	ConfidenceMedium = "medium"
+)
+
+// LossEstimate is the download loss seen from the client kernel counters only,
+// so it works with any server.
+type LossEstimate struct {
+	LossPct       float32
+	OutOfOrder    uint32 // packets queued out of order, each loss leaves a hole filled about one rtt later
+	ExtraSegs     uint32 // data segments beyond bytes/mss, duplicates but also segments shorter than the mss
+	DataSegsIn    uint32
+	ExpectedSegs  uint32 // bytes received divided by the receive mss
+	RttMs         float32
+	DsackDups     uint32   // upload direction, duplicates the server reported about our segments
+	ServerLossPct *float32 `json:",omitempty"` // Info.Loss, when the server sent its tcp info
+	Confidence    string
+	Note          string
+}
+
+// estimateLoss turns the out of order packets into lost segments, one hole
+// makes about the segments in flight during one rtt arrive out of order.
+func estimateLoss(s *network.TCPStats, downloadMs int64) *LossEstimate {
+	if s == nil || s.RcvMss == 0 {
+		return nil
+	}
+	for _, m := range s.Missing {
+		if m == "RcvOoopack" {
+			return nil
+		}
+	}
+	e := &LossEstimate{
+		OutOfOrder:   s.RcvOoopack,
+		DataSegsIn:   s.DataSegsIn,
+		ExpectedSegs: uint32((s.BytesReceived + uint64(s.RcvMss) - 1) / uint64(s.RcvMss)),
+		RttMs:        s.RcvRttMs,
+		DsackDups:    s.DsackDups,
+		Confidence:   ConfidenceMedium,
+		Note:         "reordering can not be told apart from loss on the receiving side, it raises the estimate",
+	}
+	if e.DataSegsIn > e.ExpectedSegs {
+		e.ExtraSegs = e.DataSegsIn - e.ExpectedSegs
+	}
+	if e.RttMs == 0 {
+		e.RttMs = s.RttMs
+		e.Confidence = ConfidenceLow
+		e.Note = "the transfer was too short for a receive rtt, the send rtt is used"
+	}
+	if e.ExpectedSegs < 100 {
+		e.Confidence = ConfidenceLow
+		e.Note = "less than 100 segments received"
+	}
+	if e.OutOfOrder == 0 || e.ExpectedSegs == 0 {
+		return e
+	}
+	if downloadMs <= 0 {
+		downloadMs = 1
+	}
+	// segments in flight during one rtt, at least one
+	inFlight := float64(e.ExpectedSegs) / float64(downloadMs) * float64(e.RttMs)
+	if inFlight < 1 {
+		inFlight = 1
+	}
+	lost := float64(e.OutOfOrder) / inFlight
+	if lost < 1 {
+		lost = 1
+	}
+	e.LossPct = float32(lost / float64(e.ExpectedSegs) * 100)
+	if e.LossPct > 100 {
+		e.LossPct = 100
+	}
+	return e
+}
@@ -0,0 +1,21 @@
+package http
+
+import (
+	"testing"
+
+	"github.com/qiniu/httpping/network"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimateLoss(t *testing.T) {
+	// 1000 segments in 1s with a 10ms rtt, 10 in flight, 20 out of order packets are 2 holes
+	s := &network.TCPStats{RcvMss: 1000, BytesReceived: 1000 * 1000, DataSegsIn: 1002, RcvOoopack: 20, RcvRttMs: 10}
+	e := estimateLoss(s, 1000)
+	assert.Equal(t, ConfidenceMedium, e.Confidence)
+	assert.Equal(t, uint32(1000), e.ExpectedSegs)
+	assert.Equal(t, uint32(2), e.ExtraSegs)
+	assert.InDelta(t, 0.2, e.LossPct, 0.001)
+
+	s.Missing = []string{"RcvOoopack"}
+	assert.Nil(t, estimateLoss(s, 1000))
+}
@@ -0,0 +1,249 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	ProxyHTTP   = "http"
+	ProxySOCKS5 = "socks5"
+)
+
+type ProxyInfo struct {
+	Type            string
+	Addr            string
+	Target          string // the proxy resolves it unless it is an ip
+	ConnectTimeMs   uint32
+	NegotiateTimeMs uint32 // CONNECT or socks5 handshake until the tunnel is up
+}
+
+// ParseProxy accepts http://[user:pass@]host:port and socks5://[user:pass@]host:port.
+func ParseProxy(s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case ProxyHTTP, ProxySOCKS5:
+	case "socks5h":
+		u.Scheme = ProxySOCKS5
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("proxy %q has no host", s)
+	}
+	return u, nil
+}
+
+func proxyAddr(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	if u.Scheme == ProxySOCKS5 {
+		return net.JoinHostPort(u.Hostname(), "1080")
+	}
+	return net.JoinHostPort(u.Hostname(), "80")
+}
+
+// resolveProxy looks up the proxy instead of the target, the server ip
+// given by the Pinger is sent to the proxy as the target instead of the host.
+func (t *TcpWrapper) resolveProxy(ctx context.Context, addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	t.proxyTarget = addr
+	if ip := t.pinnedIp(host); ip != "" {
+		t.proxyTarget = net.JoinHostPort(ip, port)
+	}
+	pHost, pPort, err := net.SplitHostPort(proxyAddr(t.proxy))
+	if err != nil {
+		return err
+	}
+	portNum, err := net.LookupPort(t.tcpNetwork(), pPort)
+	if err != nil {
+		return err
+	}
+	dnsStart := time.Now()
+	ip, res, err := resolveIp(ctx, t.resolver, t.tcpNetwork(), pHost)
+	if err != nil {
+		return err
+	}
+	t.dnsTime = time.Since(dnsStart)
+	t.dnsResult = res
+	t.remoteAddr = &net.TCPAddr{IP: ip, Port: portNum}
+	t.domain = host
+	return nil
+}
+
+// negotiate opens the tunnel on the raw connection, so the counters
+// and the ttfb only see the traffic to the target.
+func (t *TcpWrapper) negotiate(ctx context.Context) (err error) {
+	start := time.Now()
+	d := t.conn()
+	if deadline, ok := ctx.Deadline(); ok {
+		d.SetDeadline(deadline)
+		defer d.SetDeadline(time.Time{})
+	}
+	if t.proxy.Scheme == ProxySOCKS5 {
+		err = socks5Connect(d, t.proxy.User, t.proxyTarget)
+	} else {
+		err = httpConnect(d, t.proxy.User, t.proxyTarget)
+	}
+	t.proxyNegotiate = time.Since(start)
+	return
+}
+
+func (t *TcpWrapper) proxyInfo() *ProxyInfo {
+	return &ProxyInfo{
+		Type:            t.proxy.Scheme,
+		Addr:            proxyAddr(t.proxy),
+		Target:          t.proxyTarget,
+		ConnectTimeMs:   uint32(t.tcpHandshake.Milliseconds()),
+		NegotiateTimeMs: uint32(t.proxyNegotiate.Milliseconds()),
+	}
+}
+
+func httpConnect(conn net.Conn, user *url.Userinfo, target string) error {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: target},
+		Host:   target,
+		Header: make(http.Header),
+	}
+	if user != nil {
+		pass, _ := user.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + pass))
+		req.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+	err := req.Write(conn)
+	if err != nil {
+		return err
+	}
+	// the client speaks first in the tunnel, nothing is buffered past the response
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		return err
+	}
+	// the body of a CONNECT response is the tunnel, do not drain it
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("proxy CONNECT %s: %s", target, resp.Status)
+	}
+	return nil
+}
+
+var socks5Errors = []string{
+	1: "general failure",
+	2: "connection not allowed by ruleset",
+	3: "network unreachable",
+	4: "host unreachable",
+	5: "connection refused",
+	6: "ttl expired",
+	7: "command not supported",
+	8: "address type not supported",
+}
+
+func socks5Connect(conn net.Conn, user *url.Userinfo, target string) error {
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		return err
+	}
+	portNum, err := strconv.Atoi(port)
+	if err != nil {
+		return err
+	}
+
+	methods := []byte{5, 1, 0}
+	if user != nil {
+		methods = []byte{5, 2, 0, 2}
+	}
+	if _, err = conn.Write(methods); err != nil {
+		return err
+	}
+	b := make([]byte, 2)
+	if _, err = io.ReadFull(conn, b); err != nil {
+		return err
+	}
+	if b[0] != 5 {
+		return errors.New("socks5: unexpected version")
+	}
+	switch b[1] {
+	case 0:
+	case 2:
+		if user == nil {
+			return errors.New("socks5: proxy requires authentication")
+		}
+		pass, _ := user.Password()
+		auth := []byte{1, byte(len(user.Username()))}
+		auth = append(auth, user.Username()...)
+		auth = append(auth, byte(len(pass)))
+		auth = append(auth, pass...)
+		if _, err = conn.Write(auth); err != nil {
+			return err
+		}
+		if _, err = io.ReadFull(conn, b); err != nil {
+			return err
+		}
+		if b[1] != 0 {
+			return errors.New("socks5: authentication failed")
+		}
+	default:
+		return errors.New("socks5: no acceptable authentication method")
+	}
+
+	req := []byte{5, 1, 0}
+	if ip := net.ParseIP(host); ip == nil {
+		req = append(req, 3, byte(len(host)))
+		req = append(req, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		req = append(req, 1)
+		req = append(req, ip4...)
+	} else {
+		req = append(req, 4)
+		req = append(req, ip.To16()...)
+	}
+	req = binary.BigEndian.AppendUint16(req, uint16(portNum))
+	if _, err = conn.Write(req); err != nil {
+		return err
+	}
+
+	reply := make([]byte, 4)
+	if _, err = io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[1] != 0 {
+		if int(reply[1]) < len(socks5Errors) {
+			return errors.New("socks5: " + socks5Errors[reply[1]])
+		}
+		return fmt.Errorf("socks5: reply %d", reply[1])
+	}
+	var skip int
+	switch reply[3] {
+	case 1:
+		skip = net.IPv4len
+	case 4:
+		skip = net.IPv6len
+	case 3:
+		if _, err = io.ReadFull(conn, b[:1]); err != nil {
+			return err
+		}
+		skip = int(b[0])
+	default:
+		return errors.New("socks5: unexpected address type")
+	}
+	// the bound address and port are not used
+	_, err = io.ReadFull(conn, make([]byte, skip+2))
+	return err
+}
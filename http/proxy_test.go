@@ -0,0 +1,102 @@
+package http
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func pipe(a, b net.Conn) {
+	go io.Copy(a, b)
+	io.Copy(b, a)
+	a.Close()
+	b.Close()
+}
+
+func connectProxy(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if r.Header.Get("Proxy-Authorization") != "Basic dTpw" {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		assert.Nil(t, err)
+		pipe(conn, target)
+	}))
+}
+
+// socks5Proxy accepts no authentication and ipv4 targets only.
+func socks5Proxy(t *testing.T) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			b := make([]byte, 10)
+			io.ReadFull(conn, b[:3])
+			conn.Write([]byte{5, 0})
+			io.ReadFull(conn, b)
+			addr := net.JoinHostPort(net.IP(b[4:8]).String(), strconv.Itoa(int(binary.BigEndian.Uint16(b[8:]))))
+			target, err := net.Dial("tcp", addr)
+			if err != nil {
+				conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+				conn.Close()
+				continue
+			}
+			conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+			go pipe(conn, target)
+		}
+	}()
+	return l
+}
+
+func TestProxy(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer ts.Close()
+	hp := connectProxy(t)
+	defer hp.Close()
+	sp := socks5Proxy(t)
+	defer sp.Close()
+
+	for _, s := range []string{"http://u:p@" + hp.Listener.Addr().String(), "socks5://" + sp.Addr().String()} {
+		proxy, err := ParseProxy(s)
+		assert.Nil(t, err)
+		req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+		assert.Nil(t, err)
+		p := Pinger{Req: req, Proxy: proxy}
+		info, err := p.Ping()
+		assert.Nil(t, err)
+		assert.Empty(t, info.Error)
+		assert.Equal(t, 200, info.Code)
+		assert.Equal(t, proxy.Scheme, info.Proxy.Type)
+		assert.Equal(t, ts.Listener.Addr().String(), info.Proxy.Target)
+		assert.NotNil(t, info.TLS)
+	}
+
+	proxy, _ := ParseProxy("http://" + hp.Listener.Addr().String())
+	req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+	p := Pinger{Req: req, Proxy: proxy}
+	info, _ := p.Ping()
+	assert.Contains(t, info.Error, "407")
+}
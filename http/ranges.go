@@ -0,0 +1,325 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+type RangeCheck struct {
+	Range        string
+	Code         int
+	ContentRange []string `json:",omitempty"` // of every part
+	Ok           bool
+	Error        string `json:",omitempty"`
+}
+
+type RangeResult struct {
+	Size         int64
+	Hash         string // md5 of the full object
+	AcceptRanges string
+	Seed         int64 // gives the same ranges again
+	Checks       []RangeCheck
+	Failed       int
+	Ok           bool
+}
+
+func (r *RangeResult) String() string {
+	t, _ := json.MarshalIndent(r, "", "	")
+	return string(t)
+}
+
+type byteRange struct {
+	start, end int64 // end is included like in the headers
+}
+
+func (b byteRange) String() string {
+	return fmt.Sprintf("%d-%d", b.start, b.end)
+}
+
+type rangePart struct {
+	byteRange
+	contentRange string
+	data         []byte
+}
+
+// maxRangeObject is the largest object PingRanges keeps in memory.
+var maxRangeObject = 64 << 20
+
+// PingRanges fetches the full object, then checks the edge cases and n random
+// single and multi range requests: the status 206, the Content-Range, the
+// multipart/byteranges parts and every byte of every part. A seed of 0 uses
+// the current time. Objects larger than maxRangeObject are refused.
+func (p *Pinger) PingRanges(ctx context.Context, n int, seed int64) (*RangeResult, error) {
+	err := p.normalizeURL()
+	if err != nil {
+		return nil, err
+	}
+	// the size from a one byte range avoids downloading a too large object,
+	// a server without ranges still gets the full fetch and fails the checks
+	if size, err := p.objectSize(ctx); err == nil && size > int64(maxRangeObject) {
+		return nil, fmt.Errorf("the object has %d bytes, more than %d", size, maxRangeObject)
+	}
+	full := &limitedBuffer{limit: maxRangeObject + 1}
+	info, err := p.rangeClone(ctx, "", full).Ping()
+	if info == nil || err != nil {
+		return nil, fmt.Errorf("fetch the full object: %w", err)
+	}
+	if info.Code != http.StatusOK {
+		return nil, fmt.Errorf("fetch the full object: status %d", info.Code)
+	}
+	if full.Len() > maxRangeObject {
+		return nil, fmt.Errorf("the object has more than %d bytes", maxRangeObject)
+	}
+	if full.Len() == 0 {
+		return nil, errors.New("the object is empty, it has no range")
+	}
+	body := full.Bytes()
+	sum := md5.Sum(body)
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	r := &RangeResult{
+		Size:         int64(len(body)),
+		Hash:         hex.EncodeToString(sum[:]),
+		AcceptRanges: info.header.Get("Accept-Ranges"),
+		Seed:         seed,
+	}
+
+	rnd := rand.New(rand.NewSource(seed))
+	for _, header := range rangeHeaders(rnd, r.Size, n) {
+		var part bytes.Buffer
+		c := RangeCheck{Range: header}
+		info, err := p.rangeClone(ctx, header, &part).Ping()
+		if info == nil {
+			c.Error = err.Error()
+		} else if err != nil {
+			c.Code = info.Code
+			c.Error = err.Error()
+		} else if info.Code != http.StatusPartialContent {
+			c.Code = info.Code
+			c.Error = fmt.Sprintf("status %d, expected 206", info.Code)
+		} else {
+			c.Code = info.Code
+			parts, err := rangeParts(info.header, part.Bytes(), r.Size)
+			for _, p := range parts {
+				c.ContentRange = append(c.ContentRange, p.contentRange)
+			}
+			if err == nil {
+				err = checkParts(header, parts, body)
+			}
+			if err != nil {
+				c.Error = err.Error()
+			}
+		}
+		c.Ok = c.Error == ""
+		if !c.Ok {
+			r.Failed++
+		}
+		r.Checks = append(r.Checks, c)
+	}
+	r.Ok = r.Failed == 0
+	return r, nil
+}
+
+// rangeClone sends the request with range, or without any range when it is
+// empty, the body goes to w instead of the hasher, without the system ping.
+func (p *Pinger) rangeClone(ctx context.Context, header string, w io.Writer) *Pinger {
+	q := p.clone(ctx)
+	q.Req.Header.Del("Range")
+	if header != "" {
+		q.Req.Header.Set("Range", header)
+	}
+	q.BodyHasher = nil
+	q.HashAlgo = ""
+	q.ExpectedHash = ""
+	q.Assertions = nil
+	q.ServerSupport = false
+	q.SysPing = false
+	q.body = w
+	return q
+}
+
+// rangeHeaders starts with the edge cases, the first and the last byte, a
+// suffix and an open range, then alternates single and multi ranges.
+func rangeHeaders(rnd *rand.Rand, size int64, n int) []string {
+	suffix := size / 2
+	if suffix == 0 {
+		suffix = 1
+	}
+	headers := []string{
+		"bytes=0-0",
+		fmt.Sprintf("bytes=%d-%d", size-1, size-1),
+		fmt.Sprintf("bytes=-%d", suffix),
+		fmt.Sprintf("bytes=%d-", size/3),
+	}
+	for i := 0; i < n; i++ {
+		count := 1
+		if i%2 == 1 {
+			count = 2 + rnd.Intn(2)
+		}
+		var ranges []string
+		for _, b := range randomRanges(rnd, size, count) {
+			ranges = append(ranges, b.String())
+		}
+		headers = append(headers, "bytes="+strings.Join(ranges, ","))
+	}
+	return headers
+}
+
+// randomRanges returns count ranges in order, they do not overlap but may
+// be adjacent, then the server may merge them into one part.
+func randomRanges(rnd *rand.Rand, size int64, count int) []byteRange {
+	if size < 2 {
+		return []byteRange{{0, 0}}
+	}
+	if int64(count)*2 > size {
+		count = 1
+	}
+	points := map[int64]bool{}
+	for len(points) < count*2 {
+		points[rnd.Int63n(size)] = true
+	}
+	var sorted []int64
+	for p := range points {
+		sorted = append(sorted, p)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var ranges []byteRange
+	for i := 0; i < count; i++ {
+		ranges = append(ranges, byteRange{sorted[2*i], sorted[2*i+1]})
+	}
+	return ranges
+}
+
+// parseRangeHeader turns "bytes=0-9,-5,20-" into ranges of an object of size.
+func parseRangeHeader(header string, size int64) ([]byteRange, error) {
+	var ranges []byteRange
+	for _, spec := range strings.Split(strings.TrimPrefix(header, "bytes="), ",") {
+		var b byteRange
+		var err error
+		switch {
+		case strings.HasPrefix(spec, "-"):
+			var n int64
+			_, err = fmt.Sscanf(spec, "-%d", &n)
+			b = byteRange{size - n, size - 1}
+		case strings.HasSuffix(spec, "-"):
+			_, err = fmt.Sscanf(spec, "%d-", &b.start)
+			b.end = size - 1
+		default:
+			_, err = fmt.Sscanf(spec, "%d-%d", &b.start, &b.end)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid range %q", spec)
+		}
+		ranges = append(ranges, b)
+	}
+	return ranges, nil
+}
+
+// parseContentRange reads "bytes 0-9/100", the size may be "*".
+func parseContentRange(s string, size int64) (byteRange, error) {
+	var b byteRange
+	var total string
+	if _, err := fmt.Sscanf(s, "bytes %d-%d/%s", &b.start, &b.end, &total); err != nil {
+		return b, fmt.Errorf("invalid Content-Range %q", s)
+	}
+	if total != "*" && total != fmt.Sprint(size) {
+		return b, fmt.Errorf("Content-Range %q, the object has %d bytes", s, size)
+	}
+	if b.start > b.end || b.end >= size {
+		return b, fmt.Errorf("Content-Range %q out of the object", s)
+	}
+	return b, nil
+}
+
+// rangeParts splits a 206 response into its parts, one for a single range
+// and one per part of multipart/byteranges.
+func rangeParts(header http.Header, body []byte, size int64) ([]rangePart, error) {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/byteranges" {
+		if header.Get("Content-Range") == "" {
+			return nil, errors.New("206 without Content-Range")
+		}
+		p := rangePart{contentRange: header.Get("Content-Range"), data: body}
+		p.byteRange, err = parseContentRange(p.contentRange, size)
+		return []rangePart{p}, err
+	}
+	var parts []rangePart
+	reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return parts, fmt.Errorf("multipart/byteranges: %w", err)
+		}
+		p := rangePart{contentRange: part.Header.Get("Content-Range")}
+		p.data, err = io.ReadAll(part)
+		if err != nil {
+			return parts, fmt.Errorf("multipart/byteranges: %w", err)
+		}
+		p.byteRange, err = parseContentRange(p.contentRange, size)
+		parts = append(parts, p)
+		if err != nil {
+			return parts, err
+		}
+	}
+	if len(parts) == 0 {
+		return nil, errors.New("multipart/byteranges without parts")
+	}
+	return parts, nil
+}
+
+// checkParts compares every byte of every part with the full object, the
+// server may merge the requested ranges but the parts must cover them all.
+func checkParts(header string, parts []rangePart, full []byte) error {
+	for _, p := range parts {
+		want := full[p.start : p.end+1]
+		if len(p.data) != len(want) {
+			return fmt.Errorf("part %s has %d bytes, expected %d", p.byteRange, len(p.data), len(want))
+		}
+		if i := firstDiff(p.data, want); i >= 0 {
+			return fmt.Errorf("part %s differs from the object at byte %d", p.byteRange, p.start+int64(i))
+		}
+	}
+	ranges, err := parseRangeHeader(header, int64(len(full)))
+	if err != nil {
+		return err
+	}
+	for _, b := range ranges {
+		covered := false
+		for _, p := range parts {
+			if p.start <= b.start && b.end <= p.end {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return fmt.Errorf("range %s is missing from the response", b)
+		}
+	}
+	return nil
+}
+
+func firstDiff(a, b []byte) int {
+	for i := range a {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return -1
+}
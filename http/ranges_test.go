@@ -0,0 +1,91 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPingRanges(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdefghijklmnopqrstuvwxyz"), 1000)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rng := r.Header.Get("Range")
+		switch {
+		case r.URL.Path == "/ignored":
+			w.Write(content)
+		case r.URL.Path == "/shifted" && rng != "" && !strings.Contains(rng, ","):
+			// the right Content-Range with the bytes of another offset
+			b, _ := parseRangeHeader(rng, int64(len(content)))
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", b[0].start, b[0].end, len(content)))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(content[:b[0].end-b[0].start+1])
+		default:
+			http.ServeContent(w, r, "file.txt", time.Now(), bytes.NewReader(content))
+		}
+	}))
+	defer ts.Close()
+
+	ranges := func(path string) *RangeResult {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		assert.Nil(t, err)
+		req.Header.Set("Range", "bytes=0-9")
+		p := Pinger{Req: req}
+		r, err := p.PingRanges(req.Context(), 10, 1)
+		assert.Nil(t, err)
+		return r
+	}
+
+	r := ranges("/")
+	assert.True(t, r.Ok, r.String())
+	assert.Equal(t, int64(len(content)), r.Size)
+	assert.Equal(t, "bytes", r.AcceptRanges)
+	assert.Len(t, r.Checks, 14)
+	multi := 0
+	for _, c := range r.Checks {
+		assert.Equal(t, http.StatusPartialContent, c.Code)
+		if len(c.ContentRange) > 1 {
+			multi++
+		}
+	}
+	assert.Equal(t, 5, multi)
+
+	r = ranges("/ignored")
+	assert.False(t, r.Ok)
+	assert.Equal(t, len(r.Checks), r.Failed)
+	assert.Equal(t, "status 200, expected 206", r.Checks[0].Error)
+
+	r = ranges("/shifted")
+	assert.False(t, r.Ok)
+	assert.True(t, r.Checks[0].Ok)
+	assert.Contains(t, r.Checks[1].Error, "differs from the object")
+}
+
+func TestPingRangesMaxObject(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ignored" {
+			w.Write(content)
+			return
+		}
+		http.ServeContent(w, r, "file.txt", time.Now(), bytes.NewReader(content))
+	}))
+	defer ts.Close()
+
+	limit := maxRangeObject
+	maxRangeObject = 50
+	defer func() { maxRangeObject = limit }()
+	for _, path := range []string{"/", "/ignored"} {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		assert.Nil(t, err)
+		p := Pinger{Req: req}
+		r, err := p.PingRanges(req.Context(), 1, 1)
+		assert.Nil(t, r)
+		assert.Contains(t, err.Error(), "more than 50")
+	}
+}
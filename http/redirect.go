@@ -0,0 +1,68 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptrace"
+	"time"
+)
+
+// hopRecorder records every request of the redirect chain, the client reads
+// the body of a redirect before it sends the next request.
+type hopRecorder struct {
+	next  http.RoundTripper
+	w     *TcpWrapper
+	p     *Pinger
+	hops  []RoundTime
+	start time.Time
+	count int64
+}
+
+func (r *hopRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.finish()
+	w := r.w
+	dials := w.dialCount()
+	r.start = time.Now()
+	r.count = w.received()
+	var wrote, firstByte time.Time
+	trace := &httptrace.ClientTrace{
+		WroteRequest:         func(httptrace.WroteRequestInfo) { wrote = time.Now() },
+		GotFirstResponseByte: func() { firstByte = time.Now() },
+	}
+	resp, err := r.next.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+
+	hop := RoundTime{Url: req.URL.String(), NewConn: w.dialCount() != dials, Domain: req.URL.Hostname()}
+	if w.remoteAddr != nil {
+		hop.Ip = w.remoteAddr.IP.String()
+		hop.Port = w.remoteAddr.Port
+	}
+	if hop.NewConn {
+		hop.DnsTimeMs = uint32(w.dnsTime.Milliseconds())
+		hop.ConnectTimeMs = uint32(w.tcpHandshake.Milliseconds())
+		hop.TLSHandshakeTimeMs = uint32(w.tlsHandshake.Milliseconds())
+		if w.tlsState != nil {
+			hop.TLS = newTLSInfo(w.tlsState, w.domain, w.verifyHost, r.p.RootCAs)
+		}
+	}
+	if !wrote.IsZero() && !firstByte.IsZero() {
+		hop.TtfbMs = uint32(firstByte.Sub(wrote).Milliseconds())
+	} else {
+		// http2 does not trace, the wrapper knows its streams
+		hop.TtfbMs = uint32(w.TTFB().Milliseconds())
+	}
+	if err == nil {
+		hop.Code = resp.StatusCode
+		hop.Location = resp.Header.Get("Location")
+	}
+	r.hops = append(r.hops, hop)
+	return resp, err
+}
+
+// finish closes the last hop once its body was read.
+func (r *hopRecorder) finish() {
+	if len(r.hops) == 0 {
+		return
+	}
+	last := &r.hops[len(r.hops)-1]
+	last.TotalSize = r.w.received() - r.count
+	last.TotalTimeMs = time.Since(r.start).Milliseconds()
+}
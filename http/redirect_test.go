@@ -0,0 +1,54 @@
+package http
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedirectChain(t *testing.T) {
+	final := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer final.Close()
+	_, finalPort, _ := net.SplitHostPort(final.Listener.Addr().String())
+
+	start := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/start" {
+			http.Redirect(w, r, "/next", http.StatusFound)
+			return
+		}
+		http.Redirect(w, r, "http://cdn.test:"+finalPort+"/file", http.StatusFound)
+	}))
+	defer start.Close()
+	_, startPort, _ := net.SplitHostPort(start.Listener.Addr().String())
+
+	req, err := http.NewRequest(http.MethodGet, "http://origin.test:"+startPort+"/start", nil)
+	assert.Nil(t, err)
+	p := Pinger{Req: req, Redirect: true, HostIps: map[string]string{"origin.test": "127.0.0.1", "cdn.test": "127.0.0.1"}}
+	info, err := p.Ping()
+	assert.Nil(t, err)
+	assert.Equal(t, 200, info.Code)
+	assert.Equal(t, "cdn.test", info.Domain)
+	assert.Equal(t, "http://cdn.test:"+finalPort+"/file", info.Url)
+	if assert.Len(t, info.Rounds, 2) {
+		first, second := info.Rounds[0], info.Rounds[1]
+		assert.Equal(t, "http://origin.test:"+startPort+"/start", first.Url)
+		assert.Equal(t, http.StatusFound, first.Code)
+		assert.Equal(t, "/next", first.Location)
+		assert.True(t, first.NewConn)
+		assert.Equal(t, "127.0.0.1", first.Ip)
+		assert.Equal(t, "http://cdn.test:"+finalPort+"/file", second.Location)
+		assert.False(t, second.NewConn)
+		assert.NotZero(t, second.TotalSize)
+	}
+
+	p.Redirect = false
+	info, err = p.Ping()
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusFound, info.Code)
+	assert.Empty(t, info.Rounds)
+}
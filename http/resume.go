@@ -0,0 +1,81 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+)
+
+const (
+	ResumeTicket = "ticket" // tls 1.2 session ticket
+	ResumePSK    = "psk"    // tls 1.3 pre-shared key from NewSessionTicket
+)
+
+type ResumeResult struct {
+	Full               *Info
+	Resumed            *Info
+	FullHandshakeMs    uint32
+	ResumedHandshakeMs uint32
+	Resumption         bool
+	Mechanism          string
+	EarlyData          bool
+	EarlyDataNote      string `json:",omitempty"`
+}
+
+func (r *ResumeResult) String() string {
+	t, _ := json.MarshalIndent(r, "", "	")
+	return string(t)
+}
+
+var ErrNotTLS = errors.New("session resumption needs https")
+
+func handshakeMs(info *Info) uint32 {
+	if info.H3 != nil {
+		return info.H3.HandshakeTimeMs
+	}
+	return info.TLSHandshakeTimeMs
+}
+
+// PingResume does a full handshake and then a resumed one to the same host
+// with the session cached by the first.
+func (p *Pinger) PingResume(ctx context.Context) (*ResumeResult, error) {
+	err := p.normalizeURL()
+	if err != nil {
+		return nil, err
+	}
+	if p.Req.URL.Scheme != "https" {
+		return nil, ErrNotTLS
+	}
+	q := p.clone(ctx)
+	q.sessionCache = tls.NewLRUClientSessionCache(0)
+
+	r := &ResumeResult{}
+	r.Full, err = q.Ping()
+	if err != nil {
+		return nil, err
+	}
+	if q.NewHasher != nil {
+		q.BodyHasher = q.NewHasher()
+	}
+	r.Resumed, err = q.Ping()
+	if err != nil {
+		return nil, err
+	}
+
+	r.FullHandshakeMs = handshakeMs(r.Full)
+	r.ResumedHandshakeMs = handshakeMs(r.Resumed)
+	if t := r.Resumed.TLS; t != nil && t.Resumed {
+		r.Resumption = true
+		r.Mechanism = ResumeTicket
+		if t.Version == tls.VersionName(tls.VersionTLS13) {
+			r.Mechanism = ResumePSK
+		}
+	}
+	if r.Resumed.H3 != nil {
+		r.EarlyData = r.Resumed.H3.Used0RTT
+	} else {
+		r.EarlyDataNote = "crypto/tls does not send early data over tcp, use h3 to measure 0-RTT"
+	}
+	return r, nil
+}
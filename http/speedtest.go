@@ -0,0 +1,188 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type ConnSpeed struct {
+	Conn        int
+	Range       string `json:",omitempty"`
+	Ip          string
+	TotalSize   int64
+	TotalTimeMs int64
+	Speed       float32 // unit kb/s, Info.Speed of the connection
+	Retransmits uint32  // reported by the server, needs ServerSupport
+	LossPct     float32 // Info.ClientLoss, estimated by the client
+	Error       string  `json:",omitempty"`
+	Info        *Info   `json:"-"`
+}
+
+type SpeedTestResult struct {
+	Connections int
+	Ranges      bool  // each connection downloads its own part of the object
+	ObjectSize  int64 `json:",omitempty"`
+	TotalSize   int64
+	DurationMs  int64   // from the first dial to the end of the last download
+	Speed       float32 // unit kb/s, TotalSize over DurationMs
+	SumSpeed    float32 // the sum of the connection speeds, which do not count the connect time
+	MinSpeed    float32
+	MaxSpeed    float32
+	Fairness    float32 // Jain's index of the connection speeds, 1 when they are equal, 1/n when one takes all
+	Retransmits uint32
+	Failed      int
+	Conns       []ConnSpeed
+}
+
+func (r *SpeedTestResult) String() string {
+	t, _ := json.MarshalIndent(r, "", "	")
+	return string(t)
+}
+
+// PingParallel downloads over n connections at the same time, each with its
+// own TcpWrapper. With ranges the object is split into n ranges, else every
+// connection downloads the whole url.
+func (p *Pinger) PingParallel(ctx context.Context, n int, ranges bool) (*SpeedTestResult, error) {
+	if n <= 0 {
+		return nil, errors.New("no connection")
+	}
+	err := p.normalizeURL()
+	if err != nil {
+		return nil, err
+	}
+	r := &SpeedTestResult{Ranges: ranges}
+	var parts []byteRange
+	if ranges {
+		r.ObjectSize, err = p.objectSize(ctx)
+		if err != nil {
+			return nil, err
+		}
+		parts = splitRange(r.ObjectSize, n)
+		if len(parts) == 0 {
+			return nil, errors.New("the object is empty")
+		}
+		n = len(parts)
+	}
+	r.Connections = n
+	r.Conns = make([]ConnSpeed, n)
+
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := range r.Conns {
+		c := &r.Conns[i]
+		c.Conn = i
+		q := p.clone(ctx)
+		if ranges {
+			c.Range = "bytes=" + parts[i].String()
+			q = p.rangeClone(ctx, c.Range, nil)
+			// the retransmits come from the server
+			q.ServerSupport = p.ServerSupport
+		}
+		// the system ping would hold the connection until it ends
+		q.SysPing = false
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			info, err := q.Ping()
+			if err != nil {
+				c.Error = err.Error()
+			} else if ranges && info.Code != http.StatusPartialContent {
+				c.Error = fmt.Sprintf("status %d, expected 206", info.Code)
+			}
+			c.Info = info
+		}()
+	}
+	wg.Wait()
+	end := time.Now()
+	r.DurationMs = end.Sub(start).Milliseconds()
+
+	var speeds []float32
+	for i := range r.Conns {
+		c := &r.Conns[i]
+		info := c.Info
+		if info == nil || c.Error != "" {
+			r.Failed++
+			continue
+		}
+		c.Ip = info.Ip
+		c.TotalSize = info.TotalSize
+		c.TotalTimeMs = info.TotalTimeMs
+		c.Speed = info.Speed
+		c.Retransmits = info.ReTransmitPackets
+		if info.ClientLoss != nil {
+			c.LossPct = info.ClientLoss.LossPct
+		}
+		r.TotalSize += c.TotalSize
+		r.Retransmits += c.Retransmits
+		speeds = append(speeds, c.Speed)
+	}
+	if len(speeds) == 0 {
+		return r, fmt.Errorf("all the %d connections failed: %s", n, r.Conns[0].Error)
+	}
+	r.Speed = speed(r.TotalSize, start, end, 0)
+	r.MinSpeed, r.MaxSpeed = speeds[0], speeds[0]
+	for _, s := range speeds {
+		r.SumSpeed += s
+		if s < r.MinSpeed {
+			r.MinSpeed = s
+		}
+		if s > r.MaxSpeed {
+			r.MaxSpeed = s
+		}
+	}
+	r.Fairness = jainIndex(speeds)
+	return r, nil
+}
+
+// jainIndex is (sum x)^2 / (n * sum x^2).
+func jainIndex(x []float32) float32 {
+	var sum, squares float64
+	for _, v := range x {
+		sum += float64(v)
+		squares += float64(v) * float64(v)
+	}
+	if squares == 0 {
+		return 1
+	}
+	return float32(sum * sum / (float64(len(x)) * squares))
+}
+
+// objectSize asks for the first byte, the size is the total of its Content-Range.
+func (p *Pinger) objectSize(ctx context.Context) (int64, error) {
+	info, err := p.rangeClone(ctx, "bytes=0-0", io.Discard).Ping()
+	if info == nil || err != nil {
+		return 0, fmt.Errorf("get the object size: %w", err)
+	}
+	if info.Code != http.StatusPartialContent {
+		return 0, fmt.Errorf("get the object size: status %d, the server does not support ranges", info.Code)
+	}
+	cr := info.header.Get("Content-Range")
+	_, total, _ := strings.Cut(cr, "/")
+	size, err := strconv.ParseInt(total, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("get the object size: Content-Range %q", cr)
+	}
+	return size, nil
+}
+
+// splitRange cuts size bytes into at most n ranges of the same size.
+func splitRange(size int64, n int) []byteRange {
+	if int64(n) > size {
+		n = int(size)
+	}
+	var parts []byteRange
+	for i := 0; i < n; i++ {
+		start := size * int64(i) / int64(n)
+		end := size*int64(i+1)/int64(n) - 1
+		parts = append(parts, byteRange{start, end})
+	}
+	return parts
+}
@@ -0,0 +1,95 @@
+package http
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPingParallel(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 100000)
+	var mu sync.Mutex
+	required := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-HTTPPING-REQUIRE") != "" && r.Header.Get("Range") != "bytes=0-0" {
+			mu.Lock()
+			required++
+			mu.Unlock()
+		}
+		if r.URL.Path == "/ignored" && r.Header.Get("Range") != "bytes=0-0" {
+			w.Write(content)
+			return
+		}
+		http.ServeContent(w, r, "file", time.Now(), bytes.NewReader(content))
+	}))
+	defer ts.Close()
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+	assert.Nil(t, err)
+	p := Pinger{Req: req}
+	r, err := p.PingParallel(req.Context(), 4, false)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, r.Connections)
+	assert.Zero(t, r.Failed)
+	assert.Greater(t, r.TotalSize, int64(4*len(content)))
+	assert.Greater(t, r.Speed, float32(0))
+	assert.Greater(t, r.Fairness, float32(0.25))
+	assert.LessOrEqual(t, r.Fairness, float32(1))
+
+	r, err = p.PingParallel(req.Context(), 3, true)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(content)), r.ObjectSize)
+	assert.Equal(t, "bytes=0-333332", r.Conns[0].Range)
+	assert.Equal(t, "bytes=666666-999999", r.Conns[2].Range)
+	assert.Greater(t, r.TotalSize, int64(len(content)))
+	assert.Less(t, r.TotalSize, int64(2*len(content)))
+
+	// the server gets the tcp info request on every range
+	p.ServerSupport = true
+	r, err = p.PingParallel(req.Context(), 3, true)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, required)
+	p.ServerSupport = false
+
+	req, err = http.NewRequest(http.MethodGet, ts.URL+"/ignored", nil)
+	assert.Nil(t, err)
+	p = Pinger{Req: req}
+	r, err = p.PingParallel(req.Context(), 3, true)
+	assert.NotNil(t, err)
+	assert.Equal(t, 3, r.Failed)
+	assert.Equal(t, "status 200, expected 206", r.Conns[0].Error)
+
+	assert.Equal(t, float32(1), jainIndex([]float32{5, 5, 5}))
+	assert.Equal(t, float32(0.25), jainIndex([]float32{8, 0, 0, 0}))
+	assert.Len(t, splitRange(2, 4), 2)
+}
+
+// a system ping that hangs, like against an edge that drops icmp, must not
+// hold the connections of the speed test
+func TestPingParallelSysPing(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "ping"), []byte("#!/bin/sh\nexec sleep 2\n"), 0755))
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "file", time.Now(), bytes.NewReader(content))
+	}))
+	defer ts.Close()
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+	assert.Nil(t, err)
+	p := Pinger{Req: req, SysPing: true}
+	for _, ranges := range []bool{false, true} {
+		r, err := p.PingParallel(req.Context(), 2, ranges)
+		assert.Nil(t, err)
+		assert.Less(t, r.DurationMs, int64(1000))
+	}
+}
@@ -0,0 +1,147 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"sort"
+	"time"
+)
+
+type Stats struct {
+	Min    float64
+	Avg    float64
+	Max    float64
+	StdDev float64
+	P50    float64
+	P90    float64
+	P99    float64
+}
+
+type Summary struct {
+	Count              int
+	Success            int
+	SuccessRatio       float32
+	DnsTimeMs          Stats
+	ConnectTimeMs      Stats
+	TLSHandshakeTimeMs Stats
+	TtfbMs             Stats
+	TotalTimeMs        Stats
+	Speed              Stats // unit kb/s
+}
+
+type Result struct {
+	Infos   []*Info
+	Summary Summary
+}
+
+func (r *Result) String() string {
+	t, _ := json.MarshalIndent(r, "", "	")
+	return string(t)
+}
+
+func percentile(sorted []float64, p float64) float64 {
+	// nearest rank, same as most ping implementations
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func newStats(values []float64) Stats {
+	var s Stats
+	if len(values) == 0 {
+		return s
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	var sum float64
+	for _, v := range sorted {
+		sum += v
+	}
+	s.Min = sorted[0]
+	s.Max = sorted[len(sorted)-1]
+	s.Avg = sum / float64(len(sorted))
+
+	var variance float64
+	for _, v := range sorted {
+		variance += (v - s.Avg) * (v - s.Avg)
+	}
+	s.StdDev = math.Sqrt(variance / float64(len(sorted)))
+	s.P50 = percentile(sorted, 50)
+	s.P90 = percentile(sorted, 90)
+	s.P99 = percentile(sorted, 99)
+	return s
+}
+
+func succeeded(info *Info) bool {
+	return info.Error == "" && info.Code != 0
+}
+
+func Summarize(infos []*Info) Summary {
+	s := Summary{Count: len(infos)}
+	var dns, connect, tls, ttfb, total, speed []float64
+	for _, info := range infos {
+		if !succeeded(info) {
+			continue
+		}
+		s.Success++
+		dns = append(dns, float64(info.DnsTimeMs))
+		connect = append(connect, float64(info.ConnectTimeMs))
+		tls = append(tls, float64(info.TLSHandshakeTimeMs))
+		ttfb = append(ttfb, float64(info.TtfbMs))
+		total = append(total, float64(info.TotalTimeMs))
+		speed = append(speed, float64(info.Speed))
+	}
+	if s.Count != 0 {
+		s.SuccessRatio = float32(s.Success) / float32(s.Count)
+	}
+	s.DnsTimeMs = newStats(dns)
+	s.ConnectTimeMs = newStats(connect)
+	s.TLSHandshakeTimeMs = newStats(tls)
+	s.TtfbMs = newStats(ttfb)
+	s.TotalTimeMs = newStats(total)
+	s.Speed = newStats(speed)
+	return s
+}
+
+// PingCount pings Count times (forever if Count <= 0) with Interval between attempts,
+// like ping -c N -i S. It stops early when ctx is done and still returns the summary
+// of the finished attempts. onInfo, if not nil, is called after every attempt.
+func (p *Pinger) PingCount(ctx context.Context, onInfo func(seq int, info *Info)) (*Result, error) {
+	var r Result
+	for seq := 0; p.Count <= 0 || seq < p.Count; seq++ {
+		if seq != 0 && p.Interval > 0 {
+			timer := time.NewTimer(p.Interval)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+			case <-timer.C:
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		if p.BodyHasher != nil {
+			p.BodyHasher.Reset()
+		}
+		info, err := p.PingContext(ctx)
+		if info == nil {
+			r.Summary = Summarize(r.Infos)
+			return &r, err
+		}
+		if ctx.Err() != nil {
+			// interrupted in the middle, not a failure of the server
+			break
+		}
+		r.Infos = append(r.Infos, info)
+		if onInfo != nil {
+			onInfo(seq, info)
+		}
+	}
+	r.Summary = Summarize(r.Infos)
+	return &r, nil
+}
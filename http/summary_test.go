@@ -0,0 +1,28 @@
+package http
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummarize(t *testing.T) {
+	var infos []*Info
+	for i := 1; i <= 10; i++ {
+		infos = append(infos, &Info{Code: 200, TtfbMs: uint32(i * 10), Speed: float32(i)})
+	}
+	infos = append(infos, &Info{Error: "connection refused"})
+
+	s := Summarize(infos)
+	assert.Equal(t, 11, s.Count)
+	assert.Equal(t, 10, s.Success)
+	assert.InDelta(t, 10.0/11, s.SuccessRatio, 0.0001)
+	assert.Equal(t, 10.0, s.TtfbMs.Min)
+	assert.Equal(t, 100.0, s.TtfbMs.Max)
+	assert.Equal(t, 55.0, s.TtfbMs.Avg)
+	assert.Equal(t, 50.0, s.TtfbMs.P50)
+	assert.Equal(t, 90.0, s.TtfbMs.P90)
+	assert.Equal(t, 100.0, s.TtfbMs.P99)
+	assert.InDelta(t, 28.72, s.TtfbMs.StdDev, 0.01)
+	assert.Equal(t, Stats{}, Summarize(nil).Speed)
+}
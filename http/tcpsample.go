@@ -0,0 +1,59 @@
+package http
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/qiniu/httpping/network"
+)
+
+type TCPSample struct {
+	TimeMs int64 // since the response header
+	network.TCPSample
+}
+
+// tcpSampler reads TCP_INFO periodically while the body downloads.
+type tcpSampler struct {
+	conn    *net.TCPConn
+	start   time.Time
+	samples []TCPSample
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func sampleTCP(conn *net.TCPConn, interval time.Duration) *tcpSampler {
+	s := &tcpSampler{conn: conn, start: time.Now(), done: make(chan struct{})}
+	s.take()
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.take()
+			case <-s.done:
+				return
+			}
+		}
+	}()
+	return s
+}
+
+func (s *tcpSampler) take() {
+	sample, err := network.GetTCPSample(s.conn)
+	if err != nil {
+		return
+	}
+	s.samples = append(s.samples, TCPSample{TimeMs: time.Since(s.start).Milliseconds(), TCPSample: *sample})
+}
+
+// stop takes the last sample at the end of the body.
+func (s *tcpSampler) stop() []TCPSample {
+	close(s.done)
+	s.wg.Wait()
+	s.take()
+	return s.samples
+}
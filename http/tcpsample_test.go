@@ -0,0 +1,32 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTCPSamples(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		block := make([]byte, 64*1024)
+		for i := 0; i < 5; i++ {
+			w.Write(block)
+			w.(http.Flusher).Flush()
+			time.Sleep(30 * time.Millisecond)
+		}
+	}))
+	defer ts.Close()
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+	assert.Nil(t, err)
+	p := Pinger{Req: req, TCPSampleInterval: 20 * time.Millisecond}
+	info, err := p.Ping()
+	assert.Nil(t, err)
+	assert.True(t, len(info.TCPSamples) >= 3)
+	last := info.TCPSamples[len(info.TCPSamples)-1]
+	assert.True(t, last.TimeMs >= 100)
+	assert.True(t, last.Cwnd > 0)
+}
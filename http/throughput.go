@@ -0,0 +1,75 @@
+package http
+
+import (
+	"time"
+)
+
+const defaultThroughputInterval = 100 * time.Millisecond
+
+type Throughput struct {
+	IntervalMs     int64
+	Series         []float32 // unit kb/s, one per interval from the first response byte
+	PeakSpeed      float32
+	SteadySpeed    float32 // average after reaching 90% of the peak, without the last partial interval
+	TimeTo90PctMs  int64
+	LongestStallMs int64 // longest gap between two reads
+}
+
+// byteSeries counts the bytes received in each interval.
+type byteSeries struct {
+	interval time.Duration
+	start    time.Time
+	lastRead time.Time
+	buckets  []int64
+	stall    time.Duration
+}
+
+func (s *byteSeries) add(now time.Time, n int) {
+	if s.start.IsZero() {
+		s.start = now
+		s.lastRead = now
+	}
+	if gap := now.Sub(s.lastRead); gap > s.stall {
+		s.stall = gap
+	}
+	s.lastRead = now
+	i := int(now.Sub(s.start) / s.interval)
+	for len(s.buckets) <= i {
+		s.buckets = append(s.buckets, 0)
+	}
+	s.buckets[i] += int64(n)
+}
+
+func (s *byteSeries) throughput() *Throughput {
+	if len(s.buckets) == 0 {
+		return nil
+	}
+	ms := s.interval.Milliseconds()
+	t := &Throughput{IntervalMs: ms, LongestStallMs: s.stall.Milliseconds()}
+	for _, b := range s.buckets {
+		v := float32(float64(b) / float64(ms))
+		t.Series = append(t.Series, v)
+		if v > t.PeakSpeed {
+			t.PeakSpeed = v
+		}
+	}
+
+	reached := 0
+	for i, v := range t.Series {
+		if v >= t.PeakSpeed*0.9 {
+			reached = i
+			break
+		}
+	}
+	t.TimeTo90PctMs = int64(reached+1) * ms
+	steady := t.Series[reached:]
+	if len(steady) > 1 {
+		steady = steady[:len(steady)-1]
+	}
+	var sum float32
+	for _, v := range steady {
+		sum += v
+	}
+	t.SteadySpeed = sum / float32(len(steady))
+	return t
+}
@@ -0,0 +1,29 @@
+package http
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThroughput(t *testing.T) {
+	s := byteSeries{interval: 100 * time.Millisecond}
+	start := time.Now()
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+	// slow start, a stall from 250ms to 650ms, then steady with a partial last interval
+	s.add(at(0), 1000)
+	s.add(at(150), 5000)
+	s.add(at(250), 10000)
+	s.add(at(650), 10000)
+	s.add(at(750), 10000)
+	s.add(at(810), 100)
+
+	tp := s.throughput()
+	assert.Equal(t, int64(100), tp.IntervalMs)
+	assert.Equal(t, []float32{10, 50, 100, 0, 0, 0, 100, 100, 1}, tp.Series)
+	assert.Equal(t, float32(100), tp.PeakSpeed)
+	assert.Equal(t, int64(300), tp.TimeTo90PctMs)
+	assert.Equal(t, int64(400), tp.LongestStallMs)
+	assert.Equal(t, float32(50), tp.SteadySpeed)
+}
@@ -0,0 +1,113 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http/httptrace"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	PhaseDns     = "dns"
+	PhaseConnect = "connect" // with a proxy, also the tunnel negotiation
+	PhaseTLS     = "tls"
+	PhaseTtfb    = "ttfb" // sending the request and waiting for the first response byte
+	PhaseBody    = "body"
+)
+
+// Timeouts limits each phase of a ping, zero means no limit
+// except for Connect which keeps the default of one second.
+type Timeouts struct {
+	Dns     time.Duration
+	Connect time.Duration
+	TLS     time.Duration
+	Ttfb    time.Duration
+	Body    time.Duration
+}
+
+const defaultConnectTimeout = time.Second
+
+var errPhaseTimeout = errors.New("phase timeout")
+
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeoutCause(ctx, d, errPhaseTimeout)
+}
+
+// phaseTimer cancels the request when the ttfb or the body takes too long,
+// those phases have no context of their own inside the transport.
+type phaseTimer struct {
+	cancel context.CancelCauseFunc
+	timer  *time.Timer
+}
+
+func (t *phaseTimer) start(d time.Duration) {
+	t.stop()
+	if d > 0 {
+		t.timer = time.AfterFunc(d, func() { t.cancel(errPhaseTimeout) })
+	}
+}
+
+func (t *phaseTimer) stop() {
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+}
+
+func (t *phaseTimer) close() {
+	t.stop()
+	t.cancel(nil)
+}
+
+// phaseValue is set on the transport goroutines and read by the Pinger.
+type phaseValue struct {
+	v atomic.Value
+}
+
+func (p *phaseValue) set(phase string) {
+	p.v.Store(phase)
+}
+
+func (p *phaseValue) get() string {
+	phase, _ := p.v.Load().(string)
+	return phase
+}
+
+// phaseTrace moves the phase to ttfb and body as the request goes,
+// the body timer is started by the caller once the response is returned.
+func (p *Pinger) phaseTrace(ctx context.Context, setPhase func(string)) (context.Context, *phaseTimer) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	timer := &phaseTimer{cancel: cancel}
+	trace := &httptrace.ClientTrace{
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			setPhase(PhaseTtfb)
+			timer.start(p.Timeouts.Ttfb)
+		},
+		GotFirstResponseByte: func() {
+			setPhase(PhaseBody)
+			timer.stop()
+		},
+	}
+	return httptrace.WithClientTrace(ctx, trace), timer
+}
+
+func isTimeout(ctx context.Context, err error) bool {
+	var ne net.Error
+	return errors.Is(err, context.DeadlineExceeded) || errors.As(err, &ne) && ne.Timeout() ||
+		errors.Is(context.Cause(ctx), errPhaseTimeout)
+}
+
+// setError records err with its class, a timeout also names the phase it happened in.
+func setError(info *Info, ctx context.Context, err error, phase string) {
+	info.Error = err.Error()
+	info.ErrorPhase = phase
+	info.ErrorCode = classify(ctx, err, phase)
+	if isTimeout(ctx, err) {
+		info.TimeoutPhase = phase
+		info.Error = phase + " timeout: " + info.Error
+	}
+}
@@ -0,0 +1,117 @@
+package http
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/qiniu/httpping/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPhaseTimeouts(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow_header" {
+			time.Sleep(300 * time.Millisecond)
+		}
+		w.Header().Set("Content-Length", "10")
+		w.Write([]byte("hello"))
+		w.(http.Flusher).Flush()
+		time.Sleep(300 * time.Millisecond)
+		w.Write([]byte("world"))
+	}))
+	defer ts.Close()
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/slow_header", nil)
+	assert.Nil(t, err)
+	p := Pinger{Req: req, Timeouts: Timeouts{Ttfb: 100 * time.Millisecond}}
+	info, err := p.Ping()
+	assert.NotNil(t, err)
+	assert.Equal(t, PhaseTtfb, info.TimeoutPhase)
+	assert.Equal(t, ErrTtfbTimeout, info.ErrorCode)
+
+	req, err = http.NewRequest(http.MethodGet, ts.URL+"/slow_body", nil)
+	assert.Nil(t, err)
+	p = Pinger{Req: req, Timeouts: Timeouts{Ttfb: 100 * time.Millisecond, Body: 100 * time.Millisecond}}
+	info, err = p.Ping()
+	assert.NotNil(t, err)
+	assert.Equal(t, 200, info.Code)
+	assert.Equal(t, PhaseBody, info.TimeoutPhase)
+	assert.Equal(t, ErrBodyTimeout, info.ErrorCode)
+
+	p.Timeouts = Timeouts{}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	info, err = p.PingContext(ctx)
+	assert.NotNil(t, err)
+	assert.Equal(t, PhaseBody, info.TimeoutPhase)
+
+	info, err = p.Ping()
+	assert.Nil(t, err)
+	assert.Empty(t, info.Error)
+}
+
+// fullListener listens with a backlog of 0 and never accepts, once the queue
+// is full the kernel drops the syn and the next dial hangs.
+func fullListener(t *testing.T) string {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	assert.Nil(t, err)
+	t.Cleanup(func() { syscall.Close(fd) })
+	assert.Nil(t, syscall.Bind(fd, &syscall.SockaddrInet4{Addr: [4]byte{127, 0, 0, 1}}))
+	assert.Nil(t, syscall.Listen(fd, 0))
+	sa, err := syscall.Getsockname(fd)
+	assert.Nil(t, err)
+	addr := "127.0.0.1:" + strconv.Itoa(sa.(*syscall.SockaddrInet4).Port)
+	for i := 0; i < 16; i++ {
+		conn, err := net.DialTimeout("tcp", addr, 100*time.Millisecond)
+		if err != nil {
+			return addr
+		}
+		t.Cleanup(func() { conn.Close() })
+	}
+	t.Skip("the accept queue does not fill")
+	return ""
+}
+
+func TestSetupTimeouts(t *testing.T) {
+	ping := func(url string, timeouts Timeouts, resolver dns.Resolver) *Info {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		assert.Nil(t, err)
+		p := Pinger{Req: req, Timeouts: timeouts, Resolver: resolver}
+		start := time.Now()
+		info, err := p.Ping()
+		assert.NotNil(t, err)
+		assert.Less(t, time.Since(start), time.Second)
+		return info
+	}
+
+	info := ping("http://slow.test/", Timeouts{Dns: 100 * time.Millisecond}, stubResolver{addrs: []string{"127.0.0.1"}, delay: time.Second})
+	assert.Equal(t, PhaseDns, info.TimeoutPhase)
+	assert.Equal(t, ErrDnsTimeout, info.ErrorCode)
+
+	info = ping("http://"+fullListener(t)+"/", Timeouts{Connect: 100 * time.Millisecond}, nil)
+	assert.Equal(t, PhaseConnect, info.TimeoutPhase)
+	assert.Equal(t, ErrConnTimeout, info.ErrorCode)
+
+	// accepts and never answers the client hello
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	info = ping("https://"+l.Addr().String()+"/", Timeouts{TLS: 100 * time.Millisecond}, nil)
+	assert.Equal(t, PhaseTLS, info.TimeoutPhase)
+	assert.Equal(t, ErrTLSTimeout, info.ErrorCode)
+}
@@ -0,0 +1,199 @@
+package http
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	TLSHostnameMismatch = "hostname_mismatch"
+	TLSUnknownAuthority = "unknown_authority"
+	TLSExpired          = "expired"
+	TLSNotYetValid      = "not_yet_valid"
+	TLSInvalidCert      = "invalid_certificate"
+	TLSPinMismatch      = "pin_mismatch"
+)
+
+type CertInfo struct {
+	Subject      string
+	Issuer       string
+	SANs         []string
+	NotAfter     time.Time
+	DaysToExpiry int
+	SPKI         string // base64 sha256 of the public key, usable as a pin
+}
+
+type TLSVerifyError struct {
+	Reason   string
+	Detail   string
+	Enforced bool // false when the handshake skipped the verification and the chain was checked afterwards
+}
+
+type TLSInfo struct {
+	Version     string
+	CipherSuite string
+	ALPN        string
+	Resumed     bool
+	OCSPStapled bool
+	Certs       []CertInfo
+	Verify      *TLSVerifyError `json:",omitempty"`
+}
+
+func certInfos(certs []*x509.Certificate) []CertInfo {
+	var infos []CertInfo
+	now := time.Now()
+	for _, c := range certs {
+		sans := append([]string(nil), c.DNSNames...)
+		for _, ip := range c.IPAddresses {
+			sans = append(sans, ip.String())
+		}
+		infos = append(infos, CertInfo{
+			Subject:      c.Subject.String(),
+			Issuer:       c.Issuer.String(),
+			SANs:         sans,
+			NotAfter:     c.NotAfter,
+			DaysToExpiry: int(c.NotAfter.Sub(now).Hours() / 24),
+			SPKI:         spkiHash(c),
+		})
+	}
+	return infos
+}
+
+// verifyError turns the x509 errors into a reason.
+func verifyError(err error, certs []*x509.Certificate) *TLSVerifyError {
+	var hostErr x509.HostnameError
+	var authErr x509.UnknownAuthorityError
+	var rootsErr x509.SystemRootsError
+	var invalidErr x509.CertificateInvalidError
+	v := &TLSVerifyError{Detail: err.Error()}
+	switch {
+	case errors.As(err, &hostErr):
+		v.Reason = TLSHostnameMismatch
+	case errors.As(err, &authErr), errors.As(err, &rootsErr):
+		v.Reason = TLSUnknownAuthority
+	case errors.As(err, &invalidErr):
+		v.Reason = TLSInvalidCert
+		if invalidErr.Reason == x509.Expired {
+			v.Reason = TLSExpired
+			if len(certs) != 0 && time.Now().Before(certs[0].NotBefore) {
+				v.Reason = TLSNotYetValid
+			}
+		}
+	default:
+		v.Reason = TLSInvalidCert
+	}
+	return v
+}
+
+// verifyChain checks the chain the way the handshake would have done it.
+func verifyChain(certs []*x509.Certificate, host string, roots *x509.CertPool) error {
+	if len(certs) == 0 {
+		return nil
+	}
+	opts := x509.VerifyOptions{DNSName: host, Roots: roots, Intermediates: x509.NewCertPool()}
+	for _, c := range certs[1:] {
+		opts.Intermediates.AddCert(c)
+	}
+	_, err := certs[0].Verify(opts)
+	return err
+}
+
+func newTLSInfo(cs *tls.ConnectionState, host string, verified bool, roots *x509.CertPool) *TLSInfo {
+	info := &TLSInfo{
+		Version:     tls.VersionName(cs.Version),
+		CipherSuite: tls.CipherSuiteName(cs.CipherSuite),
+		ALPN:        cs.NegotiatedProtocol,
+		Resumed:     cs.DidResume,
+		OCSPStapled: len(cs.OCSPResponse) != 0,
+		Certs:       certInfos(cs.PeerCertificates),
+	}
+	if !verified {
+		if err := verifyChain(cs.PeerCertificates, host, roots); err != nil {
+			info.Verify = verifyError(err, cs.PeerCertificates)
+		}
+	}
+	return info
+}
+
+// failedTLSInfo reports the handshake that failed the verification.
+func failedTLSInfo(err error) *TLSInfo {
+	var pinErr *PinError
+	if errors.As(err, &pinErr) {
+		v := &TLSVerifyError{Reason: TLSPinMismatch, Detail: pinErr.Error(), Enforced: true}
+		return &TLSInfo{Certs: certInfos(pinErr.certs), Verify: v}
+	}
+	var certErr *tls.CertificateVerificationError
+	if !errors.As(err, &certErr) {
+		return nil
+	}
+	v := verifyError(certErr.Err, certErr.UnverifiedCertificates)
+	v.Enforced = true
+	return &TLSInfo{Certs: certInfos(certErr.UnverifiedCertificates), Verify: v}
+}
+
+func spkiHash(c *x509.Certificate) string {
+	sum := sha256.Sum256(c.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+type PinError struct {
+	Pins  []string
+	certs []*x509.Certificate
+}
+
+func (e *PinError) Error() string {
+	var got []string
+	for _, c := range e.certs {
+		got = append(got, spkiHash(c))
+	}
+	return fmt.Sprintf("no certificate matches the pins %v, chain has %v", e.Pins, got)
+}
+
+// checkPins passes when any certificate of the chain has one of the pins,
+// a pin is the base64 sha256 of the SPKI, optionally prefixed by sha256/.
+func checkPins(certs []*x509.Certificate, pins []string) error {
+	for _, c := range certs {
+		h := spkiHash(c)
+		for _, pin := range pins {
+			if strings.TrimPrefix(pin, "sha256/") == h {
+				return nil
+			}
+		}
+	}
+	return &PinError{Pins: pins, certs: certs}
+}
+
+// LoadRootCAs adds the pem bundle at path to the system roots.
+func LoadRootCAs(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate found in %s", path)
+	}
+	return pool, nil
+}
+
+func (p *Pinger) tlsConfig() *tls.Config {
+	cfg := &tls.Config{InsecureSkipVerify: !p.VerifyHost, Certificates: p.ClientCerts, RootCAs: p.RootCAs}
+	if len(p.Pins) != 0 {
+		pins := p.Pins
+		// also runs when the chain verification is skipped
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			return checkPins(cs.PeerCertificates, pins)
+		}
+	}
+	return cfg
+}
@@ -0,0 +1,98 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTLSInfo(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer ts.Close()
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+	assert.Nil(t, err)
+	p := Pinger{Req: req}
+	info, err := p.Ping()
+	assert.Nil(t, err)
+	assert.Equal(t, 200, info.Code)
+	assert.Equal(t, "TLS 1.3", info.TLS.Version)
+	assert.NotEmpty(t, info.TLS.CipherSuite)
+	assert.Len(t, info.TLS.Certs, 1)
+	assert.Contains(t, info.TLS.Certs[0].SANs, "127.0.0.1")
+	assert.True(t, info.TLS.Certs[0].DaysToExpiry > 0)
+	// the httptest certificate is not signed by a trusted root
+	assert.Equal(t, TLSUnknownAuthority, info.TLS.Verify.Reason)
+	assert.False(t, info.TLS.Verify.Enforced)
+
+	p.VerifyHost = true
+	info, _ = p.Ping()
+	assert.NotEmpty(t, info.Error)
+	assert.Equal(t, TLSUnknownAuthority, info.TLS.Verify.Reason)
+	assert.True(t, info.TLS.Verify.Enforced)
+}
+
+func TestClientCertAndPins(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	ts.StartTLS()
+	defer ts.Close()
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+	assert.Nil(t, err)
+	roots := x509.NewCertPool()
+	roots.AddCert(ts.Certificate())
+	p := Pinger{Req: req, VerifyHost: true, RootCAs: roots}
+	info, _ := p.Ping()
+	assert.NotEmpty(t, info.Error)
+
+	p.ClientCerts = ts.TLS.Certificates
+	info, err = p.Ping()
+	assert.Nil(t, err)
+	assert.Equal(t, 200, info.Code)
+	assert.Nil(t, info.TLS.Verify)
+
+	p.Pins = []string{"sha256/" + info.TLS.Certs[0].SPKI}
+	info, _ = p.Ping()
+	assert.Equal(t, 200, info.Code)
+
+	p.Pins = []string{"sha256/AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="}
+	p.VerifyHost = false
+	info, _ = p.Ping()
+	assert.NotEmpty(t, info.Error)
+	assert.Equal(t, TLSPinMismatch, info.TLS.Verify.Reason)
+	assert.True(t, info.TLS.Verify.Enforced)
+	assert.Equal(t, ErrTLSPinMismatch, info.ErrorCode)
+	assert.Equal(t, PhaseTLS, info.ErrorPhase)
+}
+
+func TestResume(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer ts.Close()
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+	assert.Nil(t, err)
+	p := Pinger{Req: req}
+	r, err := p.PingResume(context.Background())
+	assert.Nil(t, err)
+	assert.False(t, r.Full.TLS.Resumed)
+	assert.True(t, r.Resumption)
+	assert.Equal(t, ResumePSK, r.Mechanism)
+	assert.False(t, r.EarlyData)
+
+	// a plain ping does not resume
+	info, err := p.Ping()
+	assert.Nil(t, err)
+	assert.False(t, info.TLS.Resumed)
+}
@@ -0,0 +1,116 @@
+package http
+
+import (
+	"crypto/rand"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+type UploadInfo struct {
+	BodySize int64
+	Written  int64 // bytes written to the connection, with the request header
+	TimeMs   int64
+	Speed    float32 // unit kb/s
+	WaitMs   uint32  // from the last request byte to the first response byte
+}
+
+// randomReader repeats a random block, so a large body does not take memory.
+type randomReader struct {
+	block []byte
+	off   int
+}
+
+func (r *randomReader) Read(b []byte) (int, error) {
+	n := copy(b, r.block[r.off:])
+	r.off = (r.off + n) % len(r.block)
+	return n, nil
+}
+
+// SetRandomBody makes req upload size random bytes, the body can be replayed
+// for redirects and every attempt of PingCount.
+func SetRandomBody(req *http.Request, size int64) {
+	block := make([]byte, 64*1024)
+	rand.Read(block)
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(io.LimitReader(&randomReader{block: block}, size)), nil
+	}
+	req.Body, _ = req.GetBody()
+	req.ContentLength = size
+}
+
+// fileBody opens the file on the first read, a body that is replaced before
+// it is sent, like the one of every attempt that fails before the request,
+// holds no file.
+type fileBody struct {
+	path   string
+	mu     sync.Mutex // the transport may close the body while it reads it
+	f      *os.File
+	closed bool
+}
+
+func (b *fileBody) Read(p []byte) (int, error) {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return 0, os.ErrClosed
+	}
+	if b.f == nil {
+		f, err := os.Open(b.path)
+		if err != nil {
+			b.mu.Unlock()
+			return 0, err
+		}
+		b.f = f
+	}
+	f := b.f
+	b.mu.Unlock()
+	return f.Read(p)
+}
+
+func (b *fileBody) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed || b.f == nil {
+		b.closed = true
+		return nil
+	}
+	b.closed = true
+	return b.f.Close()
+}
+
+// SetFileBody makes req upload the file at path, every attempt reads it again.
+func SetFileBody(req *http.Request, path string) error {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	req.GetBody = func() (io.ReadCloser, error) {
+		return &fileBody{path: path}, nil
+	}
+	req.Body, _ = req.GetBody()
+	req.ContentLength = stat.Size()
+	return nil
+}
+
+func hasBody(req *http.Request) bool {
+	return req.Body != nil && req.Body != http.NoBody
+}
+
+func uploadInfo(req *http.Request, w *TcpWrapper) *UploadInfo {
+	end := w.requestEnd()
+	written, firstWrite := w.sent()
+	u := &UploadInfo{
+		BodySize: req.ContentLength,
+		Written:  written,
+		TimeMs:   end.Sub(firstWrite).Milliseconds(),
+		WaitMs:   uint32(w.TTFB().Milliseconds()),
+	}
+	t := u.TimeMs
+	if t <= 0 {
+		t = 1
+	}
+	u.Speed = float32(float64(u.Written) / float64(t))
+	return u
+}
@@ -0,0 +1,86 @@
+package http
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// openFds counts the fds of the process that point to path, -1 without /proc.
+func openFds(path string) int {
+	fds, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return -1
+	}
+	n := 0
+	for _, fd := range fds {
+		if target, _ := os.Readlink(filepath.Join("/proc/self/fd", fd.Name())); target == path {
+			n++
+		}
+	}
+	return n
+}
+
+func TestUpload(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := md5.New()
+		io.Copy(h, r.Body)
+		w.Write([]byte(hex.EncodeToString(h.Sum(nil))))
+	}))
+	defer ts.Close()
+
+	req, err := http.NewRequest(http.MethodPost, ts.URL, nil)
+	assert.Nil(t, err)
+	size := int64(1 << 20)
+	SetRandomBody(req, size)
+	p := Pinger{Req: req}
+	for i := 0; i < 2; i++ {
+		info, err := p.Ping()
+		assert.Nil(t, err)
+		if assert.NotNil(t, info.Upload) {
+			assert.Equal(t, size, info.Upload.BodySize)
+			assert.Greater(t, info.Upload.Written, size)
+			assert.Greater(t, info.Upload.Speed, float32(0))
+		}
+	}
+
+	content := []byte("the content of the uploaded file")
+	path := filepath.Join(t.TempDir(), "body")
+	assert.Nil(t, os.WriteFile(path, content, 0644))
+	sum := md5.Sum(content)
+	req, err = http.NewRequest(http.MethodPut, ts.URL, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, SetFileBody(req, path))
+	assert.Equal(t, int64(len(content)), req.ContentLength)
+	p = Pinger{Req: req, NewHasher: md5.New}
+	for i := 0; i < 3; i++ {
+		info, err := p.clone(req.Context()).Ping()
+		assert.Nil(t, err)
+		h := md5.Sum([]byte(hex.EncodeToString(sum[:])))
+		assert.Equal(t, hex.EncodeToString(h[:]), info.Hash)
+		assert.Equal(t, int64(len(content)), info.Upload.BodySize)
+	}
+
+	// a ping that fails before the request does not open the file
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	closed := l.Addr().String()
+	l.Close()
+	req, err = http.NewRequest(http.MethodPut, "http://"+closed, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, SetFileBody(req, path))
+	p = Pinger{Req: req}
+	_, err = p.Ping()
+	assert.NotNil(t, err)
+	if n := openFds(path); n >= 0 {
+		assert.Zero(t, n)
+	}
+}
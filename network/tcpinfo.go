@@ -2,7 +2,10 @@ package network
 
 import (
 	"errors"
+	"fmt"
+	"net"
 	"syscall"
+	"unsafe"
 )
 
 type TCPInfo struct {
@@ -183,6 +186,199 @@ func (t *TCPInfoMac) common() *TCPInfo {
 	return &tinfo
 }
 
+// TCPSample is the congestion state of a connection at one moment.
+type TCPSample struct {
+	RttMs        float32
+	RttVarMs     float32
+	Cwnd         uint32 // segments on linux, bytes on mac
+	Ssthresh     uint32 // 0 while still in the initial slow start
+	DeliveryRate uint64 // bytes per second, linux only
+	Retransmits  uint32
+	Unacked      uint32 // segments on linux, bytes in the send buffer on mac
+}
+
+const tcpInfiniteSsthresh = 0x7fffffff
+
+func (t *TCPInfoLinux) sample() *TCPSample {
+	s := &TCPSample{
+		RttMs:        float32(t.Tcpi_rtt) / 1000,
+		RttVarMs:     float32(t.Tcpi_rttvar) / 1000,
+		Cwnd:         t.Tcpi_snd_cwnd,
+		Ssthresh:     t.Tcpi_snd_ssthresh,
+		DeliveryRate: t.Tcpi_delivery_rate,
+		Retransmits:  t.Tcpi_total_retrans,
+		Unacked:      t.Tcpi_unacked,
+	}
+	if s.Ssthresh >= tcpInfiniteSsthresh {
+		s.Ssthresh = 0
+	}
+	return s
+}
+
+func (t *TCPInfoMac) sample() *TCPSample {
+	return &TCPSample{
+		RttMs:       float32(t.Tcpi_srtt),
+		RttVarMs:    float32(t.Tcpi_rttvar),
+		Cwnd:        t.Tcpi_snd_cwnd,
+		Ssthresh:    t.Tcpi_snd_ssthresh,
+		Retransmits: uint32(t.Tcpi_txretransmitpackets),
+		Unacked:     t.Tcpi_snd_sbbytes,
+	}
+}
+
+func GetTCPSample(tcpConn *net.TCPConn) (*TCPSample, error) {
+	_, raw, err := GetSockoptTCPInfo(tcpConn)
+	if err != nil {
+		return nil, err
+	}
+	switch info := raw.(type) {
+	case *TCPInfoLinux:
+		return info.sample(), nil
+	case *TCPInfoMac:
+		return info.sample(), nil
+	}
+	return nil, fmt.Errorf("unknown tcp info %T", raw)
+}
+
+// TCPStats is the kernel tcp_info in usual units, counters are of the whole connection.
+type TCPStats struct {
+	InfoSize               int      // bytes of tcp_info reported by the kernel
+	Missing                []string `json:",omitempty"` // fields the kernel is too old for, left 0
+	PmtuBytes              uint32
+	SndMss                 uint32
+	RcvMss                 uint32
+	SndCwnd                uint32 // segments on linux, bytes on mac
+	SndSsthresh            uint32 // 0 while still in the initial slow start
+	SndWnd                 uint32 // bytes, the window advertised by the peer
+	RcvSpace               uint32 // bytes
+	RttMs                  float32
+	RttVarMs               float32
+	MinRttMs               float32
+	RcvRttMs               float32 // estimated by the receiver, 0 until it has data to measure
+	RtoMs                  float32
+	Unacked                uint32
+	Sacked                 uint32
+	Lost                   uint32
+	Retrans                uint32
+	TotalRetrans           uint32
+	Reordering             uint32
+	PacingRate             uint64 // bytes per second
+	MaxPacingRate          uint64 // bytes per second
+	DeliveryRate           uint64 // bytes per second
+	DeliveryRateAppLimited bool
+	BytesAcked             uint64
+	BytesReceived          uint64
+	BytesSent              uint64
+	BytesRetrans           uint64
+	SegsOut                uint32
+	SegsIn                 uint32
+	DataSegsOut            uint32
+	DataSegsIn             uint32
+	NotsentBytes           uint32
+	BusyTimeMs             float64
+	RwndLimitedMs          float64 // sending limited by the receive window
+	SndbufLimitedMs        float64 // sending limited by the send buffer
+	Delivered              uint32
+	DeliveredCE            uint32
+	DsackDups              uint32
+	ReordSeen              uint32
+	RcvOoopack             uint32 // out of order packets received
+}
+
+// tcpInfoAdditions are the fields appended to tcp_info by newer kernels,
+// with the offset of the first one in each group.
+var tcpInfoAdditions = []struct {
+	offset uintptr
+	fields []string
+}{
+	{unsafe.Offsetof(TCPInfoLinux{}.Tcpi_pacing_rate), []string{"PacingRate", "MaxPacingRate"}},
+	{unsafe.Offsetof(TCPInfoLinux{}.Tcpi_bytes_acked), []string{"BytesAcked"}},
+	{unsafe.Offsetof(TCPInfoLinux{}.Tcpi_bytes_received), []string{"BytesReceived"}},
+	{unsafe.Offsetof(TCPInfoLinux{}.Tcpi_segs_out), []string{"SegsOut", "SegsIn"}},
+	{unsafe.Offsetof(TCPInfoLinux{}.Tcpi_notsent_bytes), []string{"NotsentBytes", "MinRttMs", "DataSegsIn", "DataSegsOut"}},
+	{unsafe.Offsetof(TCPInfoLinux{}.Tcpi_delivery_rate), []string{"DeliveryRate"}},
+	{unsafe.Offsetof(TCPInfoLinux{}.Tcpi_busy_time), []string{"BusyTimeMs", "RwndLimitedMs", "SndbufLimitedMs"}},
+	{unsafe.Offsetof(TCPInfoLinux{}.Tcpi_delivered), []string{"Delivered", "DeliveredCE"}},
+	{unsafe.Offsetof(TCPInfoLinux{}.Tcpi_bytes_sent), []string{"BytesSent", "BytesRetrans", "DsackDups", "ReordSeen"}},
+	{unsafe.Offsetof(TCPInfoLinux{}.Tcpi_rcv_ooopack), []string{"RcvOoopack"}},
+	{unsafe.Offsetof(TCPInfoLinux{}.Tcpi_snd_wnd), []string{"SndWnd"}},
+}
+
+func (t *TCPInfoLinux) stats(size int) *TCPStats {
+	// the kernel copied only size bytes, the rest of t is still 0
+	s := &TCPStats{
+		InfoSize:               size,
+		PmtuBytes:              t.Tcpi_pmtu,
+		SndMss:                 t.Tcpi_snd_mss,
+		RcvMss:                 t.Tcpi_rcv_mss,
+		SndCwnd:                t.Tcpi_snd_cwnd,
+		SndSsthresh:            t.Tcpi_snd_ssthresh,
+		SndWnd:                 t.Tcpi_snd_wnd,
+		RcvSpace:               t.Tcpi_rcv_space,
+		RttMs:                  float32(t.Tcpi_rtt) / 1000,
+		RttVarMs:               float32(t.Tcpi_rttvar) / 1000,
+		MinRttMs:               float32(t.Tcpi_min_rtt) / 1000,
+		RcvRttMs:               float32(t.Tcpi_rcv_rtt) / 1000,
+		RtoMs:                  float32(t.Tcpi_rto) / 1000,
+		Unacked:                t.Tcpi_unacked,
+		Sacked:                 t.Tcpi_sacked,
+		Lost:                   t.Tcpi_lost,
+		Retrans:                t.Tcpi_retrans,
+		TotalRetrans:           t.Tcpi_total_retrans,
+		Reordering:             t.Tcpi_reordering,
+		PacingRate:             t.Tcpi_pacing_rate,
+		MaxPacingRate:          t.Tcpi_max_pacing_rate,
+		DeliveryRate:           t.Tcpi_delivery_rate,
+		DeliveryRateAppLimited: t.reserved&1 != 0,
+		BytesAcked:             t.Tcpi_bytes_acked,
+		BytesReceived:          t.Tcpi_bytes_received,
+		BytesSent:              t.Tcpi_bytes_sent,
+		BytesRetrans:           t.Tcpi_bytes_retrans,
+		SegsOut:                t.Tcpi_segs_out,
+		SegsIn:                 t.Tcpi_segs_in,
+		DataSegsOut:            t.Tcpi_data_segs_out,
+		DataSegsIn:             t.Tcpi_data_segs_in,
+		NotsentBytes:           t.Tcpi_notsent_bytes,
+		BusyTimeMs:             float64(t.Tcpi_busy_time) / 1000,
+		RwndLimitedMs:          float64(t.Tcpi_rwnd_limited) / 1000,
+		SndbufLimitedMs:        float64(t.Tcpi_sndbuf_limited) / 1000,
+		Delivered:              t.Tcpi_delivered,
+		DeliveredCE:            t.Tcpi_delivered_ce,
+		DsackDups:              t.Tcpi_dsack_dups,
+		ReordSeen:              t.Tcpi_reord_seen,
+		RcvOoopack:             t.Tcpi_rcv_ooopack,
+	}
+	if s.SndSsthresh >= tcpInfiniteSsthresh {
+		s.SndSsthresh = 0
+	}
+	for _, a := range tcpInfoAdditions {
+		if a.offset >= uintptr(size) {
+			s.Missing = append(s.Missing, a.fields...)
+		}
+	}
+	return s
+}
+
+func (t *TCPInfoMac) stats() *TCPStats {
+	s := &TCPStats{
+		InfoSize:      int(unsafe.Sizeof(*t)),
+		SndMss:        t.Tcpi_maxseg,
+		SndCwnd:       t.Tcpi_snd_cwnd,
+		SndSsthresh:   t.Tcpi_snd_ssthresh,
+		SndWnd:        t.Tcpi_snd_wnd,
+		RttMs:         float32(t.Tcpi_srtt),
+		RttVarMs:      float32(t.Tcpi_rttvar),
+		RtoMs:         float32(t.Tcpi_rto),
+		TotalRetrans:  uint32(t.Tcpi_txretransmitpackets),
+		BytesSent:     t.Tcpi_txbytes,
+		BytesReceived: t.Tcpi_rxbytes,
+		BytesRetrans:  t.Tcpi_txretransmitbytes,
+		SegsOut:       uint32(t.Tcpi_txpackets),
+		SegsIn:        uint32(t.Tcpi_rxpackets),
+	}
+	return s
+}
+
 func IsEADDRINUSE(err error) bool {
 	return errors.Is(err, syscall.EADDRINUSE)
 }
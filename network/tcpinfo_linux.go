@@ -10,29 +10,47 @@ import (
 )
 
 func GetSockoptTCPInfo(tcpConn *net.TCPConn) (*TCPInfo, interface{}, error) {
+	tcpInfo, _, err := getTCPInfoLinux(tcpConn)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tcpInfo.common(), tcpInfo, nil
+}
+
+// GetTCPStats only fills the fields within the size the kernel reported,
+// older kernels return a shorter tcp_info.
+func GetTCPStats(tcpConn *net.TCPConn) (*TCPStats, error) {
+	tcpInfo, size, err := getTCPInfoLinux(tcpConn)
+	if err != nil {
+		return nil, err
+	}
+	return tcpInfo.stats(size), nil
+}
+
+func getTCPInfoLinux(tcpConn *net.TCPConn) (*TCPInfoLinux, int, error) {
 	if tcpConn == nil {
-		return nil, nil, fmt.Errorf("tcp conn is nil")
+		return nil, 0, fmt.Errorf("tcp conn is nil")
 	}
 
 	rawConn, err := tcpConn.SyscallConn()
 	if err != nil {
-		return nil, nil, fmt.Errorf("error getting raw connection. err=%v", err)
+		return nil, 0, fmt.Errorf("error getting raw connection. err=%v", err)
 	}
 
 	tcpInfo := TCPInfoLinux{}
-	size := unsafe.Sizeof(tcpInfo)
+	size := uint32(unsafe.Sizeof(tcpInfo))
 	var errno syscall.Errno
 	err = rawConn.Control(func(fd uintptr) {
 		_, _, errno = syscall.Syscall6(syscall.SYS_GETSOCKOPT, fd, syscall.SOL_TCP, syscall.TCP_INFO,
 			uintptr(unsafe.Pointer(&tcpInfo)), uintptr(unsafe.Pointer(&size)), 0)
 	})
 	if err != nil {
-		return nil, nil, fmt.Errorf("rawconn control failed. err=%v", err)
+		return nil, 0, fmt.Errorf("rawconn control failed. err=%v", err)
 	}
 
 	if errno != 0 {
-		return nil, nil, fmt.Errorf("syscall failed. errno=%d", errno)
+		return nil, 0, fmt.Errorf("syscall failed. errno=%d", errno)
 	}
 
-	return tcpInfo.common(), &tcpInfo, nil
+	return &tcpInfo, int(size), nil
 }
@@ -38,3 +38,11 @@ func GetSockoptTCPInfo(tcpConn *net.TCPConn) (*TCPInfo, interface{}, error) {
 
 	return tcpInfo.common(), &tcpInfo, nil
 }
+
+func GetTCPStats(tcpConn *net.TCPConn) (*TCPStats, error) {
+	_, raw, err := GetSockoptTCPInfo(tcpConn)
+	if err != nil {
+		return nil, err
+	}
+	return raw.(*TCPInfoMac).stats(), nil
+}
@@ -0,0 +1,22 @@
+package network
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatsOldKernel(t *testing.T) {
+	info := TCPInfoLinux{Tcpi_rtt: 1500, Tcpi_snd_ssthresh: tcpInfiniteSsthresh, Tcpi_min_rtt: 1000}
+	// a 4.9 kernel stops after tcpi_delivery_rate
+	size := int(unsafe.Offsetof(info.Tcpi_busy_time))
+	s := info.stats(size)
+	assert.Equal(t, float32(1.5), s.RttMs)
+	assert.Equal(t, float32(1), s.MinRttMs)
+	assert.Equal(t, uint32(0), s.SndSsthresh)
+	assert.NotContains(t, s.Missing, "DeliveryRate")
+	assert.Contains(t, s.Missing, "BusyTimeMs")
+	assert.Contains(t, s.Missing, "RcvOoopack")
+	assert.Empty(t, info.stats(int(unsafe.Sizeof(info))).Missing)
+}
@@ -34,12 +34,15 @@ func (c *FlvClient) Connect() (*StreamInfo, error) {
 	c.response, err = hc.Do(req)
 	if err != nil {
 		info.ErrCode = ErrTcpConnectTimeout
+		info.setError(err, tcp.Phase())
 		return info, err
 	}
 
 	info.init(tcp, c.response)
 	if c.response.StatusCode != http.StatusOK {
 		info.ErrCode = ErrInvalidHttpCode
+		info.ErrorCode = mhttp.StatusErrorCode(info.HttpCode)
+		info.ErrorPhase = mhttp.PhaseHTTP
 		return info, nil
 	}
 
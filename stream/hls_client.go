@@ -46,6 +46,7 @@ func (c *HlsClient) Connect() (*StreamInfo, error) {
 	resp, err := hc.Do(req)
 	if err != nil {
 		info.ErrCode = ErrTcpConnectTimeout
+		info.setError(err, tcp.Phase())
 		return info, err
 	}
 	defer resp.Body.Close()
@@ -53,6 +54,8 @@ func (c *HlsClient) Connect() (*StreamInfo, error) {
 	info.init(tcp, resp)
 	if resp.StatusCode != http.StatusOK {
 		info.ErrCode = ErrInvalidHttpCode
+		info.ErrorCode = mhttp.StatusErrorCode(info.HttpCode)
+		info.ErrorPhase = mhttp.PhaseHTTP
 		return info, nil
 	}
 
@@ -22,6 +22,8 @@ type StreamInfo struct {
 
 	IsConnected         bool
 	ErrCode             int
+	ErrorCode           string `json:",omitempty"` // the class of the failure, see http.ClassifyError
+	ErrorPhase          string `json:",omitempty"`
 	DnsTimeMs           uint32
 	TcpConnectTimeMs    uint32
 	TLSHandshakeTimeMs  uint32
@@ -36,6 +38,7 @@ type StreamInfo struct {
 	RemoteAddr          string
 	LocalAddr           string
 	TcpInfo             network.TCPInfo
+	TcpStats            *network.TCPStats `json:",omitempty"`
 }
 
 func (info *StreamInfo) init(tcp *mhttp.TcpWrapper, resp *http.Response) {
@@ -51,6 +54,12 @@ func (info *StreamInfo) init(tcp *mhttp.TcpWrapper, resp *http.Response) {
 	if err == nil {
 		info.TcpInfo = *tinfo
 	}
+	info.TcpStats, _ = tcp.TCPStats()
+}
+
+func (info *StreamInfo) setError(err error, phase string) {
+	info.ErrorPhase = phase
+	info.ErrorCode = mhttp.ClassifyError(err, phase)
 }
 
 func (p *Prober) Do() (*StreamInfo, error) {
@@ -108,11 +117,10 @@ func (p *Prober) do(client Client) (*StreamInfo, error) {
 				continue
 			}
 
+			player.info.setError(err, mhttp.PhaseBody)
 			return player.info, err
 		}
 
 		player.ch <- *pkt
 	}
-
-	return player.info, nil
 }